@@ -0,0 +1,19 @@
+package go_circuit_breaker
+
+// Result is the outcome of an execution delivered by ExecuteAsync
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// ExecuteAsync executes f like Execute in the background and delivers the outcome on the returned channel.
+// Rejected executions are delivered right away without running f. The channel is buffered, so the result
+// may be read at any time or never
+func (c *circuitBreaker) ExecuteAsync(f func() (interface{}, error)) <-chan Result {
+	results := make(chan Result, 1)
+	c.spawn(func() {
+		res, err := c.Execute(f)
+		results <- Result{Value: res, Err: err}
+	})
+	return results
+}
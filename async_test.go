@@ -0,0 +1,58 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestExecuteAsyncDeliversResult(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	release := make(chan struct{})
+	results := cb.ExecuteAsync(func() (interface{}, error) {
+		<-release
+		return "yay", nil
+	})
+
+	select {
+	case <-results:
+		t.Fatal("result delivered before execution returned")
+	default:
+	}
+
+	close(release)
+	assert.Equal(t, <-results, Result{Value: "yay"})
+	assert.Equal(t, cb.Stats().Successes, uint64(1))
+}
+
+func TestExecuteAsyncDeliversErrorOfFailedExecution(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	result := <-cb.ExecuteAsync(func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	})
+	assert.Equal(t, result, Result{Err: errors.New("i like to fail")})
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+}
+
+func TestExecuteAsyncDeliversRejectionWithoutExecuting(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+	cb.ForceOpen("alice")
+
+	ran := false
+	results := cb.ExecuteAsync(func() (interface{}, error) {
+		ran = true
+		return "yay", nil
+	})
+
+	select {
+	case result := <-results:
+		assert.Equal(t, result, Result{Err: errors.New("test circuit breaker open")})
+	case <-time.After(time.Second):
+		t.Fatal("rejection not delivered")
+	}
+	assert.Equal(t, ran, false)
+	assert.Equal(t, cb.Stats().Rejections, uint64(1))
+}
@@ -0,0 +1,65 @@
+package go_circuit_breaker
+
+import "time"
+
+// AuditSource tells whether a transition happened automatically or was forced by an operator
+type AuditSource string
+
+const (
+	AuditAuto   AuditSource = "auto"
+	AuditManual AuditSource = "manual"
+)
+
+// AuditEntry describes a state change and what triggered it
+type AuditEntry struct {
+	Name   string
+	Time   time.Time
+	From   State
+	To     State
+	Reason string
+	Source AuditSource
+	// Operator is set for transitions forced through methods like ForceOpen
+	Operator string
+}
+
+// AuditSink records audit entries. Entries are recorded in order and must not be modified
+type AuditSink interface {
+	Record(entry AuditEntry)
+}
+
+// cause describes what triggered a transition
+type cause struct {
+	reason   string
+	source   AuditSource
+	operator string
+}
+
+// auto returns the cause of a transition made by the circuit breaker itself
+func auto(reason string) cause {
+	return cause{reason: reason, source: AuditAuto}
+}
+
+// manual returns the cause of a transition forced by an operator
+func manual(reason, operator string) cause {
+	return cause{reason: reason, source: AuditManual, operator: operator}
+}
+
+// audit records the transition in the audit sink. Must be called with the lock held
+func (c *circuitBreaker) audit(from, to State, why cause) {
+	if c.strategy.AuditSink == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Name:     c.name,
+		Time:     c.strategy.Clock.Now(),
+		From:     from,
+		To:       to,
+		Reason:   why.reason,
+		Source:   why.source,
+		Operator: why.operator,
+	}
+	c.guard("AuditSink", func() {
+		c.strategy.AuditSink.Record(entry)
+	})
+}
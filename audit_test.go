@@ -0,0 +1,76 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (s *recordingSink) Record(entry AuditEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Time = time.Time{}
+	s.entries = append(s.entries, entry)
+}
+
+func (s *recordingSink) Entries() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditEntry(nil), s.entries...)
+}
+
+func TestAuditOfAutomaticTransitions(t *testing.T) {
+	clock := newFakeClock()
+	sink := &recordingSink{}
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock, AuditSink: sink})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "success", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	atomic.StoreInt32(&failing, 0)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	assert.Equal(t, sink.Entries(), []AuditEntry{
+		{Name: "test", From: Closed, To: HalfOpen, Reason: "failure threshold reached", Source: AuditAuto},
+		{Name: "test", From: HalfOpen, To: Closed, Reason: "recovered", Source: AuditAuto},
+	})
+}
+
+func TestAuditOfManualTransitions(t *testing.T) {
+	sink := &recordingSink{}
+	cb := NewCircuitBreaker("test", &Strategy{AuditSink: sink})
+
+	cb.ForceOpen("alice")
+	_, err := cb.Execute(func() (interface{}, error) {
+		return "success", nil
+	})
+	assert.Equal(t, err, errors.New("test circuit breaker open"))
+
+	cb.Reset("bob")
+	assert.Equal(t, cb.GetState(), Closed)
+	cb.ForceHalfOpen("carol")
+
+	assert.Equal(t, sink.Entries(), []AuditEntry{
+		{Name: "test", From: Closed, To: Open, Reason: "forced open", Source: AuditManual, Operator: "alice"},
+		{Name: "test", From: Open, To: Closed, Reason: "reset", Source: AuditManual, Operator: "bob"},
+		{Name: "test", From: Closed, To: HalfOpen, Reason: "forced half open", Source: AuditManual, Operator: "carol"},
+	})
+}
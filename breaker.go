@@ -1,42 +1,434 @@
 package go_circuit_breaker
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"golang.org/x/sync/semaphore"
+	"math/rand"
+	"sync"
 	"time"
 )
 
 type State int
 
+// String returns a readable name of the state
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half open"
+	case Open:
+		return "open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
 const (
 	Closed   State = 1
 	HalfOpen State = 2
 	Open     State = 3
 )
 
+// ErrTimeout is returned when an execution exceeds its timeout
+var ErrTimeout = errors.New("circuit breaker execution timed out")
+
+// errFailureValue is recorded for executions returning a value classified as failure
+var errFailureValue = errors.New("circuit breaker execution returned a failure value")
+
+// ErrLoadShed is returned when an execution is rejected to shed load
+var ErrLoadShed = errors.New("circuit shedding load. latency above target")
+
+// ErrBelowLatencyFloor flags successful probes faster than MinSuccessLatency
+var ErrBelowLatencyFloor = errors.New("circuit breaker probe succeeded below latency floor")
+
+// neutralError marks an outcome counting neither as success nor as failure
+type neutralError struct {
+	err error
+}
+
+func (e *neutralError) Error() string {
+	return e.err.Error()
+}
+
+func (e *neutralError) Unwrap() error {
+	return e.err
+}
+
+// tripError marks an error counting as failure regardless of IsFailure
+type tripError struct {
+	err error
+}
+
+func (e *tripError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tripError) Unwrap() error {
+	return e.err
+}
+
+// Neutral wraps an error returned by an executed function so that this execution counts neither
+// as success nor as failure. The caller receives err unwrapped
+func Neutral(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &neutralError{err: err}
+}
+
+// Trip wraps an error returned by an executed function so that it counts as failure even when IsFailure
+// or ConnectErrorsCount would ignore it. The caller receives err unwrapped
+func Trip(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &tripError{err: err}
+}
+
+// ErrConnect marks failures to reach the dependency at all, as opposed to failures of operations on it.
+// Wrap connect errors with it, like fmt.Errorf("%w: %v", ErrConnect, err), for ConnectErrorsCount to tell them apart
+var ErrConnect = errors.New("circuit breaker dependency unreachable")
+
+// ErrFallbackPending is returned for rejected executions when the fallback runs asynchronously
+var ErrFallbackPending = errors.New("circuit breaker fallback running asynchronously")
+
+// CallsUntilTripUnknown is returned by CallsUntilTrip when the failure counter does not count consecutive failures
+const CallsUntilTripUnknown = -1
+
 const defaultErrorThreshold = 5
 const defaultRetryInterval = 5
 const defaultRetryMax = 5
+const defaultSuccessThreshold = 1
+const defaultHalfOpenShareTimeout = time.Millisecond * 100
+
+// Logger is used by the circuit breaker to report alerts and slow executions
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdoutLogger prints log entries to stdout
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format, v...)
+}
 
 // Strategy holds variables to configure circuit breaker
 type Strategy struct {
 	Threshold     int
 	RetryInterval int
 	RetryMax      int
+	// SuccessThreshold sets the consecutive successful probes needed to close. Defaults to 1
+	SuccessThreshold int
+	// SuccessWeight sets how much the result of a successful probe counts toward SuccessThreshold. Defaults to 1
+	SuccessWeight func(res interface{}) int
+	// MinSuccessLatency ignores successful probes faster than this for recovery as they likely hit a cache
+	// or short circuit. Such probes emit a probe event with ErrBelowLatencyFloor. Disabled when zero
+	MinSuccessLatency time.Duration
+	// ManualRecoveryOnly trips straight to Open and stays there until Reset, without any probing
+	ManualRecoveryOnly bool
+	// SoftOpenSampleRate admits this fraction of the executions in Open state instead of rejecting all of them.
+	// Sampled executions probe the dependency and close the circuit breaker after SuccessThreshold successes
+	SoftOpenSampleRate float64
+	// HalfOpenFailureGrace sets how many failed probes are tolerated per HalfOpen period. Tolerated failures
+	// neither reset the successes counted toward SuccessThreshold nor count toward reopening, so closing takes
+	// precedence over them. Failures beyond the grace reset the successes and count as usual
+	HalfOpenFailureGrace int
+	// PostRecoveryProbation sets how long after recovering the circuit breaker trips on more than
+	// ProbationThreshold consecutive failures instead of the decision of its failure counter
+	PostRecoveryProbation time.Duration
+	ProbationThreshold    int
+
+	// Timeout fails executions taking longer than this. The function keeps running in the background,
+	// with ExecuteWithContext its context is cancelled. Disabled when zero
+	Timeout time.Duration
+	// AllowDoubleExecute restores the legacy behavior of executing f a second time after it succeeded in Closed
+	// state and returning the result of the second execution. Only safe for idempotent functions
+	AllowDoubleExecute bool
+
+	// IsFailure decides whether an error counts as failure. Every error counts when nil
+	IsFailure func(err error) bool
+	// ConnectErrorsCount counts errors wrapping ErrConnect as failures. Otherwise executions failing to connect
+	// count neither as failure nor as success, as they might be caused by a local network issue,
+	// and only failed operations on the reachable dependency trip the circuit breaker
+	ConnectErrorsCount bool
+	// IsFailureValue decides whether the result of an execution without error counts as failure
+	IsFailureValue func(res interface{}) bool
+
+	// OnAdmit is called whenever an execution or recovery probe is allowed to run
+	OnAdmit func(name string, state State)
+	// OnStateChange is called on every state change while the circuit breaker is locked. Must not call back into it
+	OnStateChange func(name string, from, to State)
+	// OnCountsReset is called with the counts about to be cleared on close, Reset or restoring a state.
+	// Called while the circuit breaker is locked. Must not call back into it
+	OnCountsReset func(name string, previous Counts)
+	// TransitionGuard can veto automatic transitions by returning false. A vetoed trip keeps the breaker closed,
+	// a vetoed close or open keeps it recovering
+	TransitionGuard func(from, to State, counts Counts) bool
+	// OnInternalError receives panics recovered from user supplied hooks. Logged when nil
+	OnInternalError func(name string, err error)
+
+	// CategoryThresholds trips when the consecutive failures of an error category exceed its threshold,
+	// in addition to Threshold. Categories are assigned by ErrorCategory
+	CategoryThresholds map[string]int
+	// ErrorCategory assigns failures to a category of CategoryThresholds
+	ErrorCategory func(err error) string
+
+	// DedupWindow counts identical consecutive errors within this window as a single failure. Disabled when zero
+	DedupWindow time.Duration
+	// ErrorKey identifies identical errors for DedupWindow. Compares error messages when nil
+	ErrorKey func(err error) string
+
+	// SlowLogThreshold logs every execution taking longer than this. Disabled when zero
+	SlowLogThreshold time.Duration
+	// Logger receives alerts and slow execution entries. Defaults to stdout
+	Logger Logger
+
+	// AlertAfter only alerts once the breaker failed to close for this long after tripping.
+	// Alerts on every rejected execution when zero
+	AlertAfter time.Duration
+	// Clock drives recovery and scheduled checks. Defaults to the system time
+	Clock Clock
+
+	// LoadShedTarget rejects a share of executions proportional to how far the p95 latency
+	// of recent executions exceeds it. Disabled when zero
+	LoadShedTarget time.Duration
+
+	// LatencyBuckets sets the upper bounds of the latency histogram. Defaults to buckets from 10ms to 5s
+	LatencyBuckets []time.Duration
+	// ErrorRateWindow sets how far back RecentErrorRate can look. Defaults to one minute
+	ErrorRateWindow time.Duration
+
+	// ProbeFunc checks the dependency during recovery instead of executing the tripping function again.
+	// Once recovering failed it keeps probing in Open state and closes when the dependency recovered
+	ProbeFunc func() error
+	// ProbeWithContext is a context aware alternative to ProbeFunc taking precedence over it
+	ProbeWithContext func(ctx context.Context) error
+	// ProbeTimeout sets the deadline of the context passed to ProbeWithContext.
+	// A probe still running at the deadline is abandoned and counts as failed. Disabled when zero
+	ProbeTimeout time.Duration
+	// Fallback serves rejected executions. It receives the error the execution was rejected with
+	Fallback func(err error) (interface{}, error)
+	// AsyncFallback runs the fallback in the background and returns ErrFallbackPending immediately.
+	// The result of the fallback is discarded, so it has to deliver its degraded response by itself
+	AsyncFallback bool
+
+	// HalfOpenEntryErrorReset sets the failures carried into HalfOpen. Failed probes then count on top
+	// and reopen the breaker once the counter trips again, in place of RetryMax. The lower the value,
+	// the more failed probes recovery survives. Disabled when zero
+	HalfOpenEntryErrorReset int
+	// CountProbeFailuresInWindow records failed probes in HalfOpen with the failure counter deciding when
+	// to trip and keeps its window when recovering, so they count toward the first trip after recovery.
+	// Meant for time windowed counters, which let old failures age out on their own
+	CountProbeFailuresInWindow bool
+
+	// HalfOpenShareProbeResult lets executions rejected while a recovery probe is in flight
+	// wait for the probe and return its result instead. Only use it for idempotent functions.
+	// Has no effect with a probe function, as its result is not one of the executed function
+	HalfOpenShareProbeResult bool
+	// HalfOpenShareTimeout bounds the wait for the probe result. Defaults to 100ms
+	HalfOpenShareTimeout time.Duration
+
+	// AuditSink records every state change with what triggered it
+	AuditSink AuditSink
+	// HistorySize sets how many of the latest state changes TransitionHistory retains. Disabled when zero
+	HistorySize int
+
+	// EventBuffer sets how many events are buffered before dropping them. Defaults to 64
+	EventBuffer int
+	// MaxEventRate limits the state change events emitted per second. Changes within the interval are coalesced
+	// into a single event from the first to the last state, dropped when both are the same. Unlimited when zero
+	MaxEventRate float64
+
+	// CorrelationIDKey is the context key of the correlation id added to errors of ExecuteWithContext
+	CorrelationIDKey interface{}
+
+	// IdleTimeout evicts circuit breakers from a Registry once they were not looked up for this long.
+	// Disabled when zero
+	IdleTimeout time.Duration
+	// MaxConcurrentProbes caps the probes running at once across the circuit breakers of a Registry, so a shared
+	// outage is not followed by a burst of probes. Background recovery probes queue, executions admitted as probes
+	// are rejected while the cap is reached. Unlimited when zero
+	MaxConcurrentProbes int
+	// MaxKeys caps the circuit breakers a Registry creates on lookup. At the cap the least recently used
+	// closed circuit breaker is evicted, or the shared overflow circuit breaker is returned when none is closed.
+	// Unlimited when zero
+	MaxKeys int
+}
+
+// Counts holds the execution outcomes since the circuit breaker last closed
+type Counts struct {
+	Requests             uint64
+	TotalSuccesses       uint64
+	TotalFailures        uint64
+	ConsecutiveSuccesses uint64
+	ConsecutiveFailures  uint64
+}
+
+// Stats holds accumulated statistics of a circuit breaker
+type Stats struct {
+	// Requests counts admitted executions
+	Requests   uint64
+	Successes  uint64
+	Failures   uint64
+	Rejections uint64
+	// StateChanges counts all state changes, including those coalesced by MaxEventRate
+	StateChanges uint64
+
+	// Latency counts executions per bucket. Executions slower than the last bucket are only part of the totals
+	Latency      []LatencyBucket
+	LatencyCount uint64
+	LatencySum   time.Duration
+
+	// Recoveries counts closes after the circuit breaker tripped, manual ones included
+	Recoveries uint64
+	// LastRecoveryDuration is how long the circuit breaker was not closed before it last closed
+	LastRecoveryDuration time.Duration
+	// AverageRecoveryDuration is the mean time to recovery over all recoveries
+	AverageRecoveryDuration time.Duration
 }
 
 type circuitBreaker struct {
-	name              string
-	strategy          *Strategy
-	state             State
-	consecutiveErrors int
+	mu       sync.Mutex
+	name     string
+	strategy *Strategy
+	state    State
+	counter  FailureCounter
+	probe    *probeCall
+
+	// pinned holds the breaker in a forced state until Reset. In HalfOpen it admits one probe execution per retry interval
+	pinned bool
+	// callerProbes lets HalfOpen admit one execution per retry interval as probe when there is no recovery running
+	callerProbes   bool
+	lastProbe      time.Time
+	probeSuccesses int
+	probeFailures  int
+	graceFailures  int
+	// nextRetry is when the running recovery probes next
+	nextRetry time.Time
+	// cooldownUntil delays the first probe of a recovery resumed by restoring a state
+	cooldownUntil time.Time
+
+	stats  Stats
+	counts Counts
+
+	lastFailureKey string
+	lastFailureAt  time.Time
+	// categoryFailures counts consecutive failures per error category
+	categoryFailures map[string]int
+	lastEvaluation   TripEvaluation
+	trips            uint64
+	// openedAt is when the circuit breaker last left Closed, zero while closed
+	openedAt time.Time
+	// changedAt is when the state last changed
+	changedAt time.Time
+	// recoveryTotal sums the durations of all recoveries
+	recoveryTotal time.Duration
+	// probationUntil ends the PostRecoveryProbation after the last recovery
+	probationUntil time.Time
+
+	latencies latencyTracker
+	outcomes  outcomeBuckets
+	random    func() float64
+
+	events        chan Event
+	droppedEvents uint64
+	// goroutines counts the running background goroutines, which stop ends where possible
+	goroutines int64
+	stop       chan struct{}
+	stopOnce   sync.Once
+	// lastStateEvent is when the last state change event was emitted and pendingState the one held back
+	lastStateEvent time.Time
+	pendingState   *Event
+	history        []StateChange
+
+	// store persists every state change when created by NewWithStore
+	store Store
+	// probeLimiter bounds the recovery probes across the circuit breakers of a Registry
+	probeLimiter *probeLimiter
+	// probeSlots counts the slots of probeLimiter held by executions admitted as probes
+	probeSlots int
+	// permits of operations allowed by Allow whose outcome Record did not report yet
+	gatePermits gatePermits
+
+	// semaphore bounds concurrent executions through ExecuteWithContext, each holding semaphoreWeight
+	semaphore       *semaphore.Weighted
+	semaphoreWeight int64
+}
+
+// probeCall is a recovery attempt in flight
+type probeCall struct {
+	done chan struct{}
+	res  interface{}
+	err  error
+}
+
+// Option configures optional behaviour of a circuit breaker
+type Option func(*circuitBreaker)
+
+// WithInitialState starts the circuit breaker in the given state instead of Closed.
+// Starting in HalfOpen admits one execution per retry interval until SuccessThreshold of them succeeded.
+// Open is kept only when SoftOpenSampleRate or ManualRecoveryOnly leads out of it, otherwise the circuit breaker
+// starts in HalfOpen to not stay open for good. Unknown states are ignored
+func WithInitialState(state State) Option {
+	return func(c *circuitBreaker) {
+		switch state {
+		case Closed, HalfOpen:
+		case Open:
+			if !c.leavesOpen() {
+				state = HalfOpen
+			}
+		default:
+			return
+		}
+		c.state = state
+		c.callerProbes = state == HalfOpen
+	}
+}
+
+// WithCounter replaces the default consecutive failure counter deciding when to trip
+func WithCounter(counter FailureCounter) Option {
+	return func(c *circuitBreaker) {
+		c.counter = counter
+	}
+}
+
+// Inspector defines read-only access to a circuit breaker
+type Inspector interface {
+	GetState() State
+	GetName() string
 }
 
 // CircuitBreaker defines the circuit breaker decorator interface
 type CircuitBreaker interface {
+	Inspector
 	Execute(func() (interface{}, error)) (interface{}, error)
-	GetState() State
-	GetName() string
+	ExecuteWithTimeout(d time.Duration, f func() (interface{}, error)) (interface{}, error)
+	ExecuteIfClosed(f func() (interface{}, error)) (interface{}, error, bool)
+	ExecuteAsync(f func() (interface{}, error)) <-chan Result
+	ExecuteWithContext(ctx context.Context, f func(ctx context.Context) (interface{}, error)) (interface{}, error)
+	Go(f func() error)
+	Stop()
+	ActiveGoroutines() int
+	WouldTrip(err error) bool
+	EvaluateTrip() bool
+	LastTripEvaluation() TripEvaluation
+	CallsUntilTrip() int
+	Stats() Stats
+	RecentErrorRate(d time.Duration) float64
+	Counts() Counts
+	SuggestStrategy() Strategy
+	ForceHalfOpen(operator string)
+	ForceOpen(operator string)
+	Reset(operator string)
+	Events() <-chan Event
+	DroppedEvents() uint64
+	Allow() bool
+	Record(success bool)
+	TransitionHistory() []StateChange
+	MarshalJSON() ([]byte, error)
 }
 
 // GetName returns name of circuit breaker
@@ -46,11 +438,14 @@ func (c *circuitBreaker) GetName() string {
 
 // GetState returns state of circuit breaker
 func (c *circuitBreaker) GetState() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	return c.state
 }
 
 // NewCircuitBreaker returns new instance of circuit breaker
-func NewCircuitBreaker(name string, strategy *Strategy) CircuitBreaker {
+func NewCircuitBreaker(name string, strategy *Strategy, opts ...Option) CircuitBreaker {
 	if strategy.Threshold <= 0 {
 		strategy.Threshold = defaultErrorThreshold
 	}
@@ -63,63 +458,1029 @@ func NewCircuitBreaker(name string, strategy *Strategy) CircuitBreaker {
 		strategy.RetryInterval = defaultRetryInterval
 	}
 
-	return &circuitBreaker{
-		name:              name,
-		strategy:          strategy,
-		state:             Closed,
-		consecutiveErrors: 0,
+	if strategy.SuccessThreshold <= 0 {
+		strategy.SuccessThreshold = defaultSuccessThreshold
+	}
+
+	if strategy.Logger == nil {
+		strategy.Logger = stdoutLogger{}
+	}
+
+	if strategy.Clock == nil {
+		strategy.Clock = realClock{}
+	}
+
+	if strategy.EventBuffer <= 0 {
+		strategy.EventBuffer = defaultEventBuffer
+	}
+
+	if len(strategy.LatencyBuckets) == 0 {
+		strategy.LatencyBuckets = defaultLatencyBuckets
+	}
+
+	if strategy.ErrorRateWindow <= 0 {
+		strategy.ErrorRateWindow = defaultErrorRateWindow
+	}
+
+	if strategy.HalfOpenShareTimeout <= 0 {
+		strategy.HalfOpenShareTimeout = defaultHalfOpenShareTimeout
+	}
+
+	c := &circuitBreaker{
+		name:      name,
+		strategy:  strategy,
+		state:     Closed,
+		counter:   NewConsecutiveCounter(strategy.Threshold),
+		events:    make(chan Event, strategy.EventBuffer),
+		stop:      make(chan struct{}),
+		latencies: newLatencyTracker(strategy.LatencyBuckets),
+		outcomes:  newOutcomeBuckets(strategy.ErrorRateWindow),
+		random:    rand.Float64,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // Execute executes a function wrapped in a circuit breaker pattern
 func (c *circuitBreaker) Execute(f func() (interface{}, error)) (interface{}, error) {
-	switch c.state {
-	case Closed:
-		res, err := f()
-		if err != nil {
-			c.handleError(f)
-			return res, err
+	return c.ExecuteWithTimeout(c.strategy.Timeout, f)
+}
+
+// ExecuteWithTimeout executes a function wrapped in a circuit breaker pattern, failing it when it takes longer than d.
+// Overrides the timeout of the strategy for this execution only
+func (c *circuitBreaker) ExecuteWithTimeout(d time.Duration, f func() (interface{}, error)) (interface{}, error) {
+	res, err := c.execute(c.timed(d, f), true)
+	if openErr, ok := err.(*OpenError); ok {
+		return c.fallback(openErr.err)
+	}
+	return res, err
+}
+
+// ExecuteIfClosed executes f only when the circuit breaker is Closed, reporting whether f was admitted.
+// Never probes nor falls back: in any other state, or when the execution is shed, f does not run.
+// Neither is f retried for recovery when it trips the circuit breaker
+func (c *circuitBreaker) ExecuteIfClosed(f func() (interface{}, error)) (interface{}, error, bool) {
+	state, _, _, shed := c.admission(true)
+	if shed {
+		return nil, ErrLoadShed, false
+	}
+	if state != Closed {
+		return nil, nil, false
+	}
+
+	res, err := c.executeClosed(c.timed(c.strategy.Timeout, f), false)
+	return res, err, true
+}
+
+// fallback serves a rejected execution from the fallback if configured
+func (c *circuitBreaker) fallback(err error) (interface{}, error) {
+	if c.strategy.Fallback == nil {
+		return nil, err
+	}
+
+	if c.strategy.AsyncFallback {
+		c.spawn(func() {
+			c.guard("Fallback", func() {
+				c.strategy.Fallback(err)
+			})
+		})
+		return nil, ErrFallbackPending
+	}
+
+	var res interface{}
+	resErr := err
+	c.guard("Fallback", func() {
+		res, resErr = c.strategy.Fallback(err)
+	})
+	return res, resErr
+}
+
+// timed returns f failing with ErrTimeout when it takes longer than d. Returns f unchanged when d is zero
+func (c *circuitBreaker) timed(d time.Duration, f func() (interface{}, error)) func() (interface{}, error) {
+	if d <= 0 {
+		return f
+	}
+
+	type result struct {
+		res interface{}
+		err error
+	}
+
+	return func() (interface{}, error) {
+		done := make(chan result, 1)
+		c.spawn(func() {
+			res, err := f()
+			done <- result{res, err}
+		})
+
+		select {
+		case r := <-done:
+			return r.res, r.err
+		case <-c.strategy.Clock.After(d):
+			return nil, ErrTimeout
 		}
+	}
+}
+
+// execute executes f according to the state and returns rejections as *OpenError.
+// Unless replay is set, f is not retried when it trips the circuit breaker
+func (c *circuitBreaker) execute(f func() (interface{}, error), replay bool) (interface{}, error) {
+	state, probe, callerProbe, shed := c.admission(false)
+	if shed {
+		return nil, ErrLoadShed
+	}
 
-		c.handleSuccess()
+	if callerProbe {
+		c.admit(state)
+		res, elapsed, err := c.measure(f)
+		c.releaseProbeSlot()
+		failure, err, counts := c.classify(res, err)
+		if counts {
+			c.handleProbe(failure, c.belowLatencyFloor(elapsed), c.successWeight(res))
+		}
+		return res, err
+	}
+
+	switch state {
+	case Closed:
+		return c.executeClosed(f, replay)
 	case HalfOpen:
-		return nil, errors.New("circuit half open. trying to recover")
+		if c.strategy.HalfOpenShareProbeResult && probe != nil && !c.hasProbe() {
+			select {
+			case <-probe.done:
+				return probe.res, probe.err
+			case <-c.strategy.Clock.After(c.strategy.HalfOpenShareTimeout):
+			}
+		}
+		return nil, c.rejection(HalfOpen)
 	case Open:
-		message := fmt.Sprintf("%v circuit breaker open", c.name)
-		fmt.Printf("ALERT: %v", message)
-		return nil, errors.New(message)
+		return nil, c.rejection(Open)
+	}
+	return f()
+}
+
+// rejection returns the error of an execution rejected in state. Alerts on every rejection in Open
+// unless AlertAfter is set
+func (c *circuitBreaker) rejection(state State) *OpenError {
+	if state == HalfOpen {
+		return c.openError(HalfOpen, errors.New("circuit half open. trying to recover"))
+	}
+
+	message := fmt.Sprintf("%v circuit breaker open", c.name)
+	if c.strategy.AlertAfter <= 0 {
+		c.logf("ALERT: %v", message)
+	}
+	return c.openError(Open, errors.New(message))
+}
+
+// executeClosed runs f admitted in Closed and records its outcome. Recovers by retrying f if replay is set
+func (c *circuitBreaker) executeClosed(f func() (interface{}, error), replay bool) (interface{}, error) {
+	c.admit(Closed)
+	res, _, err := c.measure(f)
+	failure, err, counts := c.classify(res, err)
+	if !counts {
+		return res, err
+	}
+	if failure != nil {
+		recovery := f
+		if !replay {
+			recovery = nil
+		}
+		c.handleError(recovery, failure)
+		return res, err
+	}
+
+	c.handleSuccess()
+	if err != nil || !c.strategy.AllowDoubleExecute {
+		return res, err
 	}
 	return f()
 }
 
+// openError returns the error rejecting an execution in state
+func (c *circuitBreaker) openError(state State, err error) *OpenError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &OpenError{Name: c.name, State: state, RetryAfter: c.retryAfter(), err: err}
+}
+
+// retryAfter returns how long until retrying may succeed or zero when the circuit breaker does not probe
+// on its own. In HalfOpen that is the next probe, in Open the earliest close after the remaining probes.
+// Must be called with the lock held
+func (c *circuitBreaker) retryAfter() time.Duration {
+	interval := time.Second * time.Duration(c.strategy.RetryInterval)
+
+	var next time.Time
+	switch {
+	case c.state == HalfOpen && (c.pinned || c.callerProbes):
+		next = c.lastProbe.Add(interval)
+	case c.pinned || c.strategy.ManualRecoveryOnly || c.state == Closed || c.state == Open && !c.hasProbe():
+		return 0
+	default:
+		next = c.nextRetry
+	}
+
+	var remaining time.Duration
+	if now := c.strategy.Clock.Now(); next.After(now) {
+		remaining = next.Sub(now)
+	}
+	if c.state == Open {
+		// closing takes the missing successful probes, one per retry interval
+		if probes := c.strategy.SuccessThreshold - c.probeSuccesses - 1; probes > 0 {
+			remaining += time.Duration(probes) * interval
+		}
+	}
+	return remaining
+}
+
+// admission decides whether the next execution is rejected, shed or admitted as probe and counts it.
+// With closedOnly no execution is admitted as probe
+func (c *circuitBreaker) admission(closedOnly bool) (state State, probe *probeCall, callerProbe, shed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, probe = c.state, c.probe
+	callerProbe = !closedOnly && (state == HalfOpen && (c.pinned || c.callerProbes) && c.probeDue() ||
+		c.softOpen() && c.random() < c.strategy.SoftOpenSampleRate && c.acquireProbeSlot())
+	if state != Closed && !callerProbe {
+		c.emit(Event{Type: EventReject, Severity: Warning})
+	}
+	shed = state == Closed && c.strategy.LoadShedTarget > 0 && c.random() < c.shedProbability()
+	if shed {
+		c.emit(Event{Type: EventReject, Severity: Warning, Err: ErrLoadShed})
+	}
+	if state != Closed && !callerProbe || shed {
+		c.stats.Rejections++
+	} else {
+		c.stats.Requests++
+		c.counts.Requests++
+	}
+	return state, probe, callerProbe, shed
+}
+
+// Stats returns accumulated statistics of circuit breaker
+func (c *circuitBreaker) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Latency = c.latencies.histogram()
+	stats.LatencyCount = c.latencies.count
+	stats.LatencySum = c.latencies.sum
+	return stats
+}
+
+// Counts returns the execution outcomes since circuit breaker last closed
+func (c *circuitBreaker) Counts() Counts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts
+}
+
+// ForceHalfOpen holds the circuit breaker in HalfOpen until Reset, admitting one execution per retry interval.
+// The outcomes of these executions do not change the state. The operator is recorded in the audit log
+func (c *circuitBreaker) ForceHalfOpen(operator string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pinned = true
+	c.lastProbe = time.Time{}
+	if c.state != HalfOpen {
+		c.setState(HalfOpen, manual("forced half open", operator))
+	} else {
+		c.persist()
+	}
+}
+
+// ForceOpen holds the circuit breaker in Open until Reset, rejecting all executions.
+// The operator is recorded in the audit log
+func (c *circuitBreaker) ForceOpen(operator string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pinned = true
+	c.callerProbes = false
+	if c.state != Open {
+		c.setState(Open, manual("forced open", operator))
+	} else {
+		c.persist()
+	}
+}
+
+// Reset closes the circuit breaker and clears its failures. The operator is recorded in the audit log
+func (c *circuitBreaker) Reset(operator string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pinned = false
+	if c.state != Closed {
+		c.close(manual("reset", operator))
+	} else {
+		c.resetCounts()
+	}
+}
+
+// probeDue reports whether HalfOpen admits the next execution as probe and reserves it.
+// Must be called with the lock held
+func (c *circuitBreaker) probeDue() bool {
+	now := c.strategy.Clock.Now()
+	interval := time.Second * time.Duration(c.strategy.RetryInterval)
+	if !c.lastProbe.IsZero() && now.Sub(c.lastProbe) < interval || !c.acquireProbeSlot() {
+		return false
+	}
+
+	c.lastProbe = now
+	return true
+}
+
+// acquireProbeSlot takes a slot of the probe limiter for an execution admitted as probe without waiting
+// and reports whether it got one. Must be called with the lock held
+func (c *circuitBreaker) acquireProbeSlot() bool {
+	if c.probeLimiter == nil {
+		return true
+	}
+	if !c.probeLimiter.tryAcquire() {
+		return false
+	}
+	c.probeSlots++
+	return true
+}
+
+// releaseProbeSlot frees a slot taken by an execution admitted as probe once its outcome is known
+func (c *circuitBreaker) releaseProbeSlot() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.probeSlots > 0 {
+		c.probeSlots--
+		c.probeLimiter.release()
+	}
+}
+
+// handleProbe records the outcome of an execution admitted as probe in HalfOpen.
+// Outcomes only change the state when not pinned. Fast successes do not count toward recovery,
+// others count with their weight
+func (c *circuitBreaker) handleProbe(failure error, fast bool, weight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes.record(failure == nil, c.strategy.Clock.Now())
+	if failure != nil {
+		c.stats.Failures++
+		c.emit(Event{Type: EventProbe, Severity: Warning, Err: failure})
+	} else {
+		c.stats.Successes++
+		c.emitProbeSuccess(fast)
+	}
+
+	softOpen := c.softOpen()
+	if c.pinned || !(c.callerProbes && c.state == HalfOpen || softOpen) || failure == nil && fast {
+		return
+	}
+
+	if failure != nil {
+		if c.graced() {
+			return
+		}
+		c.probeSuccesses = 0
+		if softOpen {
+			return
+		}
+		if c.countsProbeFailures() {
+			c.counter.Record(false, c.strategy.Clock.Now())
+		}
+		c.probeFailures++
+		exhausted := c.probeFailures > c.strategy.RetryMax
+		if c.strategy.HalfOpenEntryErrorReset > 0 {
+			exhausted = c.counter.ShouldTrip()
+		}
+		if exhausted && c.allowTransition(HalfOpen, Open) {
+			c.emit(Event{Type: EventRecoveryExhausted, Severity: Critical})
+			c.callerProbes = false
+			c.setState(Open, auto("recovery exhausted"))
+		}
+		return
+	}
+
+	c.probeSuccesses += weight
+	if c.probeSuccesses >= c.strategy.SuccessThreshold && c.allowTransition(c.state, Closed) {
+		c.close(auto("recovered"))
+	}
+}
+
+// leavesOpen reports whether soft open samples or a Reset lead out of Open without a probe function
+func (c *circuitBreaker) leavesOpen() bool {
+	return c.strategy.SoftOpenSampleRate > 0 || c.strategy.ManualRecoveryOnly
+}
+
+// softOpen reports whether Open admits a sample of the executions as probes. Must be called with the lock held
+func (c *circuitBreaker) softOpen() bool {
+	return c.state == Open && !c.pinned && !c.strategy.ManualRecoveryOnly && c.strategy.SoftOpenSampleRate > 0
+}
+
+// graced reports whether a failed probe in HalfOpen is tolerated by HalfOpenFailureGrace and uses up
+// one of them. Must be called with the lock held
+func (c *circuitBreaker) graced() bool {
+	if c.state != HalfOpen || c.pinned || c.graceFailures >= c.strategy.HalfOpenFailureGrace {
+		return false
+	}
+	c.graceFailures++
+	return true
+}
+
+// countsProbeFailures reports whether failed probes are recorded with the failure counter. Must be called with the lock held
+func (c *circuitBreaker) countsProbeFailures() bool {
+	return c.state == HalfOpen && !c.pinned && (c.strategy.CountProbeFailuresInWindow || c.strategy.HalfOpenEntryErrorReset > 0)
+}
+
+// emitProbeSuccess emits a successful probe, flagging it when it was too fast to count
+func (c *circuitBreaker) emitProbeSuccess(fast bool) {
+	if fast {
+		c.emit(Event{Type: EventProbe, Severity: Warning, Err: ErrBelowLatencyFloor})
+		return
+	}
+	c.emit(Event{Type: EventProbe, Severity: Info})
+}
+
+// WouldTrip reports whether recording err as the next outcome would trip the circuit breaker
+func (c *circuitBreaker) WouldTrip(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != Closed || !c.isFailure(err) || c.uncountedConnectError(err) {
+		return false
+	}
+	category := c.errorCategory(err)
+	if threshold, ok := c.strategy.CategoryThresholds[category]; ok && c.categoryFailures[category]+1 > threshold {
+		return true
+	}
+
+	if c.onProbation() {
+		return c.counts.ConsecutiveFailures+1 > uint64(c.strategy.ProbationThreshold)
+	}
+
+	predictor, ok := c.counter.(TripPredictor)
+	if !ok {
+		return false
+	}
+	return predictor.WouldTrip(c.strategy.Clock.Now())
+}
+
+// logf writes a log entry to the configured logger
+func (c *circuitBreaker) logf(format string, v ...interface{}) {
+	c.guard("Logger", func() {
+		c.strategy.Logger.Printf(format, v...)
+	})
+}
+
+// guard runs a user supplied hook and reports a panic instead of propagating it
+func (c *circuitBreaker) guard(hook string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.internalError(fmt.Errorf("%v panicked: %v", hook, r))
+		}
+	}()
+	f()
+}
+
+// internalError reports err to the internal error sink. Panics of the sink itself are dropped
+func (c *circuitBreaker) internalError(err error) {
+	defer func() {
+		recover()
+	}()
+
+	if c.strategy.OnInternalError != nil {
+		c.strategy.OnInternalError(c.name, err)
+		return
+	}
+	c.strategy.Logger.Printf("ERROR: %v circuit breaker %v", c.name, err)
+}
+
+// admit reports an admitted execution in the given state
+func (c *circuitBreaker) admit(state State) {
+	if c.strategy.OnAdmit != nil {
+		c.guard("OnAdmit", func() {
+			c.strategy.OnAdmit(c.name, state)
+		})
+	}
+}
+
+// CallsUntilTrip returns how many more consecutive failures trip the circuit breaker, applying the probation
+// threshold after recovering. Returns zero when already tripped and CallsUntilTripUnknown for counters other
+// than the consecutive one outside probation
+func (c *circuitBreaker) CallsUntilTrip() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.counter.(*consecutiveCounter)
+	switch {
+	case c.state != Closed:
+		return 0
+	case c.onProbation():
+		return c.strategy.ProbationThreshold - int(c.counts.ConsecutiveFailures) + 1
+	case !ok:
+		return CallsUntilTripUnknown
+	}
+	return counter.threshold - counter.failures + 1
+}
+
+// failureOf returns the failure an execution is counted with or nil when it counts as success
+func (c *circuitBreaker) failureOf(res interface{}, err error) error {
+	if err != nil {
+		if c.isFailure(err) {
+			return err
+		}
+		return nil
+	}
+	if c.isFailureValue(res) {
+		return errFailureValue
+	}
+	return nil
+}
+
+// classify applies the overrides of Neutral and Trip to the outcome of an execution. Returns the failure to record,
+// the error to return unwrapped and whether the outcome counts at all
+func (c *circuitBreaker) classify(res interface{}, err error) (failure, unwrapped error, counts bool) {
+	switch e := err.(type) {
+	case *neutralError:
+		return nil, e.err, false
+	case *tripError:
+		return e.err, e.err, true
+	}
+	if c.uncountedConnectError(err) {
+		return nil, err, false
+	}
+	return c.failureOf(res, err), err, true
+}
+
+// uncountedConnectError reports whether err is a connect error not counting as failure
+func (c *circuitBreaker) uncountedConnectError(err error) bool {
+	return !c.strategy.ConnectErrorsCount && errors.Is(err, ErrConnect)
+}
+
+// isFailureValue classifies the result of an execution without error using the strategy
+func (c *circuitBreaker) isFailureValue(res interface{}) bool {
+	if c.strategy.IsFailureValue == nil {
+		return false
+	}
+
+	failure := false
+	c.guard("IsFailureValue", func() {
+		failure = c.strategy.IsFailureValue(res)
+	})
+	return failure
+}
+
+// successWeight returns how much a successful probe result counts toward SuccessThreshold
+func (c *circuitBreaker) successWeight(res interface{}) int {
+	if c.strategy.SuccessWeight == nil {
+		return 1
+	}
+
+	weight := 1
+	c.guard("SuccessWeight", func() {
+		weight = c.strategy.SuccessWeight(res)
+	})
+	if weight < 0 {
+		return 0
+	}
+	return weight
+}
+
+// isFailure classifies err using the strategy
+func (c *circuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if c.strategy.IsFailure == nil {
+		return true
+	}
+
+	// count the error when the classifier panics
+	failure := true
+	c.guard("IsFailure", func() {
+		failure = c.strategy.IsFailure(err)
+	})
+	return failure
+}
+
+// shedProbability returns the share of executions to reject based on the p95 latency overage.
+// Must be called with the lock held
+func (c *circuitBreaker) shedProbability() float64 {
+	target := c.strategy.LoadShedTarget
+	p95 := c.latencies.percentile(0.95)
+	if p95 <= target {
+		return 0
+	}
+
+	overage := float64(p95-target) / float64(target)
+	if overage > 1 {
+		return 1
+	}
+	return overage
+}
+
+// measure executes f, returning how long it took, and logs the execution if it exceeds the slow log threshold
+func (c *circuitBreaker) measure(f func() (interface{}, error)) (interface{}, time.Duration, error) {
+	start := time.Now()
+	res, err := f()
+	elapsed := time.Since(start)
+
+	c.mu.Lock()
+	c.latencies.record(elapsed)
+	c.mu.Unlock()
+
+	if c.strategy.SlowLogThreshold > 0 && elapsed > c.strategy.SlowLogThreshold {
+		c.logf("SLOW: %v circuit breaker execution took %v", c.name, elapsed)
+	}
+	return res, elapsed, err
+}
+
+// belowLatencyFloor reports whether a successful probe was too fast to count toward recovery
+func (c *circuitBreaker) belowLatencyFloor(elapsed time.Duration) bool {
+	return c.strategy.MinSuccessLatency > 0 && elapsed < c.strategy.MinSuccessLatency
+}
+
 func (c *circuitBreaker) handleSuccess() {
-	c.consecutiveErrors = 0
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Successes++
+	c.outcomes.record(true, c.strategy.Clock.Now())
+	c.counts.TotalSuccesses++
+	c.counts.ConsecutiveSuccesses++
+	c.counts.ConsecutiveFailures = 0
+	c.lastFailureAt = time.Time{}
+	c.counter.Record(true, c.strategy.Clock.Now())
+	c.categoryFailures = nil
+}
+
+func (c *circuitBreaker) handleError(f func() (interface{}, error), err error) {
+	key := c.errorKey(err)
+	category := c.errorCategory(err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Failures++
+	c.outcomes.record(false, c.strategy.Clock.Now())
+	c.counts.TotalFailures++
+	c.counts.ConsecutiveFailures++
+	c.counts.ConsecutiveSuccesses = 0
+
+	now := c.strategy.Clock.Now()
+	if c.strategy.DedupWindow > 0 {
+		// identical errors within the window were already counted
+		if !c.lastFailureAt.IsZero() && c.lastFailureKey == key && now.Sub(c.lastFailureAt) < c.strategy.DedupWindow {
+			return
+		}
+		c.lastFailureKey, c.lastFailureAt = key, now
+	}
+
+	c.counter.Record(false, now)
+	c.recordCategory(category)
+	c.evaluateTrip(f, err)
+}
+
+// EvaluateTrip runs the trip decision against the current counts without recording an outcome
+// and trips if warranted. Reports whether it tripped
+func (c *circuitBreaker) EvaluateTrip() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.evaluateTrip(nil, nil)
+}
+
+// evaluateTrip trips the closed circuit breaker when the counter says so and starts recovering with f.
+// Must be called with the lock held
+func (c *circuitBreaker) evaluateTrip(f func() (interface{}, error), err error) bool {
+	if c.state != Closed {
+		return false
+	}
+
+	category, categoryTrip := c.trippedCategory()
+	shouldTrip := c.counter.ShouldTrip()
+	if c.onProbation() {
+		shouldTrip = c.counts.ConsecutiveFailures > uint64(c.strategy.ProbationThreshold)
+	}
+	shouldTrip = shouldTrip || categoryTrip
+	to := HalfOpen
+	if c.strategy.ManualRecoveryOnly {
+		to = Open
+	}
+	tripped := shouldTrip && c.allowTransition(Closed, to)
+	c.lastEvaluation = c.evaluation(shouldTrip, tripped)
+	c.lastEvaluation.Category = category
+	if !tripped {
+		return false
+	}
+
+	c.emit(Event{Type: EventTrip, Severity: Warning, Err: err})
+	c.trips++
+	c.probeSuccesses = 0
+	c.graceFailures = 0
+	c.setState(to, auto("failure threshold reached"))
+
+	if c.strategy.HalfOpenEntryErrorReset > 0 && to == HalfOpen {
+		c.counter.Reset()
+		now := c.strategy.Clock.Now()
+		for i := 0; i < c.strategy.HalfOpenEntryErrorReset; i++ {
+			c.counter.Record(false, now)
+		}
+	}
+	switch {
+	case c.strategy.ManualRecoveryOnly:
+		// stays open until Reset
+	case f == nil && !c.hasProbe() || c.stopped():
+		// without a function to retry or after Stop, admitted executions probe the dependency
+		c.callerProbes = true
+	default:
+		c.spawn(func() {
+			c.recover(f)
+		})
+	}
+
+	if c.strategy.AlertAfter > 0 {
+		trips := c.trips
+		c.strategy.Clock.AfterFunc(c.strategy.AlertAfter, func() {
+			c.alertIfNotClosed(trips)
+		})
+	}
+	return true
 }
 
-func (c *circuitBreaker) handleError(f func() (interface{}, error)) {
-	c.consecutiveErrors++
-	if c.consecutiveErrors > c.strategy.Threshold {
-		c.state = HalfOpen
-		go c.recover(f)
+// errorKey identifies err for deduplication
+func (c *circuitBreaker) errorKey(err error) string {
+	key := err.Error()
+	if c.strategy.DedupWindow > 0 && c.strategy.ErrorKey != nil {
+		c.guard("ErrorKey", func() {
+			key = c.strategy.ErrorKey(err)
+		})
 	}
+	return key
 }
 
+// allowTransition asks the transition guard whether an automatic transition may happen.
+// Must be called with the lock held
+func (c *circuitBreaker) allowTransition(from, to State) bool {
+	if c.strategy.TransitionGuard == nil {
+		return true
+	}
+
+	allowed := true
+	c.guard("TransitionGuard", func() {
+		allowed = c.strategy.TransitionGuard(from, to, c.counts)
+	})
+	if !allowed {
+		c.logf("WARNING: %v circuit breaker transition from %v to %v vetoed", c.name, from, to)
+	}
+	return allowed
+}
+
+// resetCounts clears the outcomes deciding on the next transition. Must be called with the lock held
+func (c *circuitBreaker) resetCounts() {
+	c.clearCounts(false)
+}
+
+// clearCounts works like resetCounts, leaving the window of the failure counter as is with keepWindow.
+// Must be called with the lock held
+func (c *circuitBreaker) clearCounts(keepWindow bool) {
+	if c.strategy.OnCountsReset != nil {
+		previous := c.counts
+		c.guard("OnCountsReset", func() {
+			c.strategy.OnCountsReset(c.name, previous)
+		})
+	}
+
+	c.counts = Counts{}
+	c.lastFailureAt = time.Time{}
+	c.probeSuccesses = 0
+	c.probeFailures = 0
+	c.graceFailures = 0
+	c.categoryFailures = nil
+	if !keepWindow {
+		c.counter.Reset()
+	}
+}
+
+// close clears the counts and closes the circuit breaker. Recovering starts the PostRecoveryProbation.
+// Must be called with the lock held
+func (c *circuitBreaker) close(why cause) {
+	c.callerProbes = false
+	c.clearCounts(why.source == AuditAuto && c.strategy.CountProbeFailuresInWindow)
+	c.probationUntil = time.Time{}
+	if why.source == AuditAuto && c.strategy.PostRecoveryProbation > 0 {
+		c.probationUntil = c.strategy.Clock.Now().Add(c.strategy.PostRecoveryProbation)
+	}
+	c.setState(Closed, why)
+}
+
+// setState changes the state, emits the change and audits its cause. Must be called with the lock held
+func (c *circuitBreaker) setState(to State, why cause) {
+	from := c.state
+	c.state = to
+	c.changedAt = c.strategy.Clock.Now()
+	if to == Closed {
+		c.recovered()
+	} else if from == Closed {
+		c.openedAt = c.strategy.Clock.Now()
+	}
+	c.emitStateChange(Event{Type: EventStateChange, Severity: stateSeverity(to), From: from, To: to, Manual: why.source == AuditManual})
+	c.audit(from, to, why)
+	c.remember(from, to, why)
+	c.persist()
+
+	if c.strategy.OnStateChange != nil {
+		c.guard("OnStateChange", func() {
+			c.strategy.OnStateChange(c.name, from, to)
+		})
+	}
+}
+
+// recovered measures the time since the circuit breaker left Closed. Must be called with the lock held
+func (c *circuitBreaker) recovered() {
+	if c.openedAt.IsZero() {
+		return
+	}
+
+	d := c.strategy.Clock.Now().Sub(c.openedAt)
+	c.openedAt = time.Time{}
+	c.recoveryTotal += d
+	c.stats.Recoveries++
+	c.stats.LastRecoveryDuration = d
+	c.stats.AverageRecoveryDuration = c.recoveryTotal / time.Duration(c.stats.Recoveries)
+}
+
+// onProbation reports whether the PostRecoveryProbation is running. Must be called with the lock held
+func (c *circuitBreaker) onProbation() bool {
+	return c.strategy.Clock.Now().Before(c.probationUntil)
+}
+
+// alertIfNotClosed alerts when the breaker did not recover in time from the trip counted as trips.
+// A later trip schedules its own alert
+func (c *circuitBreaker) alertIfNotClosed(trips uint64) {
+	c.mu.Lock()
+	alert := c.trips == trips && c.state != Closed
+	c.mu.Unlock()
+
+	if alert {
+		c.logf("ALERT: %v circuit breaker open for %v", c.name, c.strategy.AlertAfter)
+	}
+}
+
+// probeOnce checks the dependency with the probe function if configured or executes f otherwise
+func (c *circuitBreaker) probeOnce(f func() (interface{}, error)) (interface{}, error) {
+	if c.strategy.ProbeWithContext != nil {
+		return nil, c.probeWithContext()
+	}
+	if c.strategy.ProbeFunc != nil {
+		err := errors.New("probe panicked")
+		c.guard("ProbeFunc", func() {
+			err = c.strategy.ProbeFunc()
+		})
+		return nil, err
+	}
+	return f()
+}
+
+// hasProbe reports whether the strategy checks the dependency with a probe function
+func (c *circuitBreaker) hasProbe() bool {
+	return c.strategy.ProbeFunc != nil || c.strategy.ProbeWithContext != nil
+}
+
+// probeWithContext runs the context aware probe and abandons it after the probe timeout
+func (c *circuitBreaker) probeWithContext() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if c.strategy.ProbeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), c.strategy.ProbeTimeout)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	c.spawn(func() {
+		err := errors.New("probe panicked")
+		c.guard("ProbeWithContext", func() {
+			err = c.strategy.ProbeWithContext(ctx)
+		})
+		done <- err
+	})
+
+	var timeout <-chan time.Time
+	if c.strategy.ProbeTimeout > 0 {
+		timeout = c.strategy.Clock.After(c.strategy.ProbeTimeout)
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeout:
+		return context.DeadlineExceeded
+	case <-c.stop:
+		return errStopped
+	}
+}
+
+// recover probes the dependency until the circuit breaker closes or recovering failed.
+// With a probe function it keeps probing in Open state until the dependency recovered
 func (c *circuitBreaker) recover(f func() (interface{}, error)) {
 	retries := 0
-	for c.state == HalfOpen {
+	for {
+		c.mu.Lock()
+		if c.pinned || c.strategy.ManualRecoveryOnly || c.stopped() || c.state == Closed || c.state == Open && !c.hasProbe() {
+			c.mu.Unlock()
+			return
+		}
+
 		// Open circuit breaker when recovering fails
-		if retries > c.strategy.RetryMax {
-			c.state = Open
+		if c.state == HalfOpen && c.strategy.HalfOpenEntryErrorReset <= 0 && retries > c.strategy.RetryMax {
+			if c.allowTransition(HalfOpen, Open) {
+				c.emit(Event{Type: EventRecoveryExhausted, Severity: Critical})
+				c.setState(Open, auto("recovery exhausted"))
+				c.mu.Unlock()
+				continue
+			}
+			retries = 0
+		}
+		wait := time.Second * time.Duration(c.strategy.RetryInterval)
+		if !c.cooldownUntil.IsZero() {
+			wait = c.cooldownUntil.Sub(c.strategy.Clock.Now())
+			c.cooldownUntil = time.Time{}
+			if wait < 0 {
+				wait = 0
+			}
+		}
+		c.nextRetry = c.strategy.Clock.Now().Add(wait)
+		limiter := c.probeLimiter
+		c.mu.Unlock()
+
+		select {
+		case <-c.after(wait):
+		case <-c.stop:
+			return
+		}
+		if limiter != nil && !limiter.acquire(c.stop) {
 			return
 		}
 
-		time.Sleep(time.Second * time.Duration(c.strategy.RetryInterval))
+		c.mu.Lock()
+		state := c.state
+		probe := &probeCall{done: make(chan struct{})}
+		c.probe = probe
+		c.mu.Unlock()
 
 		// set state to closed if request is successful
-		_, err := f()
-		if err == nil {
-			c.state = Closed
+		c.admit(state)
+		start := time.Now()
+		res, err := c.probeOnce(f)
+		fast := c.belowLatencyFloor(time.Since(start))
+		if limiter != nil {
+			limiter.release()
+		}
+		failure, err, counts := c.classify(res, err)
+
+		c.mu.Lock()
+		probe.res, probe.err = res, err
+		close(probe.done)
+		c.probe = nil
+
+		if !counts {
+			// neutral outcomes neither fail nor succeed and do not count as retry
+			c.mu.Unlock()
+			continue
+		}
+
+		if failure != nil {
+			c.emit(Event{Type: EventProbe, Severity: Warning, Err: failure})
+			if c.graced() {
+				// tolerated failures keep the successes and do not count as retry
+				c.mu.Unlock()
+				continue
+			}
+			c.probeSuccesses = 0
+
+			if c.countsProbeFailures() {
+				c.counter.Record(false, c.strategy.Clock.Now())
+				if c.strategy.HalfOpenEntryErrorReset > 0 && c.counter.ShouldTrip() && c.allowTransition(HalfOpen, Open) {
+					c.emit(Event{Type: EventRecoveryExhausted, Severity: Critical})
+					c.setState(Open, auto("recovery exhausted"))
+				}
+			}
+		} else {
+			c.emitProbeSuccess(fast)
+			if !fast {
+				c.probeSuccesses += c.successWeight(res)
+				if c.state != Closed && !c.pinned && c.probeSuccesses >= c.strategy.SuccessThreshold &&
+					c.allowTransition(c.state, Closed) {
+					c.close(auto("recovered"))
+				}
+			}
 		}
+		c.mu.Unlock()
 
 		retries++
 	}
@@ -1,12 +1,35 @@
 package go_circuit_breaker
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/magiconair/properties/assert"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// recordingLogger keeps every log entry in memory
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Entries() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
 func TestWhenThresholdExceededStateIsHalfOpenError(t *testing.T) {
 	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2})
 
@@ -99,3 +122,1644 @@ func TestWhenRecoverSucceedsStateIsClosed(t *testing.T) {
 	assert.Equal(t, err, nil)
 	assert.Equal(t, res, "yay")
 }
+
+func TestWhenExecutionIsSlowEntryIsLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	cb := NewCircuitBreaker("test", &Strategy{SlowLogThreshold: time.Millisecond * 10, Logger: logger})
+
+	slowFunc := func() (interface{}, error) {
+		time.Sleep(time.Millisecond * 20)
+		return nil, errors.New("i like to be slow")
+	}
+
+	cb.Execute(slowFunc)
+
+	entries := logger.Entries()
+	assert.Equal(t, len(entries), 1)
+	assert.Matches(t, entries[0], "^SLOW: test circuit breaker execution took ")
+}
+
+func TestWhenExecutionIsFastNothingIsLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	cb := NewCircuitBreaker("test", &Strategy{SlowLogThreshold: time.Second, Logger: logger})
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	cb.Execute(happyFunc)
+
+	assert.Equal(t, len(logger.Entries()), 0)
+}
+
+func TestWhenBreakerRecoversBeforeAlertAfterNoAlertIsLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, AlertAfter: time.Second * 10, Logger: logger, Clock: clock})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// wait until recovery and alert are scheduled
+	waitFor(t, func() bool { return clock.Pending() == 2 })
+
+	// first retry succeeds
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	clock.Advance(time.Second * 10)
+	assert.Equal(t, len(logger.Entries()), 0)
+}
+
+func TestWhenBreakerStaysOpenPastAlertAfterAlertIsLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, AlertAfter: time.Second * 10, Logger: logger, Clock: clock})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	// wait until recovery and alert are scheduled
+	waitFor(t, func() bool { return clock.Pending() == 2 })
+
+	clock.Advance(time.Second * 10)
+	assert.Equal(t, logger.Entries(), []string{"ALERT: test circuit breaker open for 10s"})
+}
+
+func TestWhenBreakerTripsAgainAfterRecoveringOnlyTheLatestTripAlerts(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, AlertAfter: time.Second * 10, Logger: logger, Clock: clock})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	waitFor(t, func() bool { return clock.Pending() == 2 })
+
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	// trips again before the alert of the first trip is due
+	atomic.StoreInt32(&failing, 1)
+	clock.Advance(time.Second)
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	waitFor(t, func() bool { return clock.Pending() == 3 })
+
+	clock.Advance(time.Second * 8)
+	assert.Equal(t, len(logger.Entries()), 0)
+
+	clock.Advance(time.Second * 2)
+	assert.Equal(t, logger.Entries(), []string{"ALERT: test circuit breaker open for 10s"})
+}
+
+func TestWouldTripReportsWhetherNextFailureTrips(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), false)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), true)
+	assert.Equal(t, cb.WouldTrip(nil), false)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestWouldTripIgnoresErrorsWhichAreNoFailures(t *testing.T) {
+	ignored := errors.New("not found")
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, IsFailure: func(err error) bool {
+		return err != ignored
+	}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.WouldTrip(ignored), false)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), true)
+}
+
+// probeInFlight reports whether a recovery probe is currently executing
+func probeInFlight(cb CircuitBreaker) bool {
+	c := cb.(*circuitBreaker)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.probe != nil
+}
+
+func TestWhenProbeSucceedsQuicklyResultIsShared(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, HalfOpenShareProbeResult: true, Clock: clock})
+
+	var failing int32 = 1
+	release := make(chan struct{})
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		<-release
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// start a probe which blocks until released
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return probeInFlight(cb) })
+
+	type result struct {
+		res interface{}
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		res, err := cb.Execute(testFunc)
+		results <- result{res, err}
+	}()
+
+	// waiting caller registered its timeout
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	close(release)
+
+	r := <-results
+	assert.Equal(t, r.err, nil)
+	assert.Equal(t, r.res, "yay")
+}
+
+func TestWhenProbeIsSlowSharingTimesOut(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, HalfOpenShareProbeResult: true, Clock: clock})
+
+	var failing int32 = 1
+	release := make(chan struct{})
+	defer close(release)
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		<-release
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// start a probe which blocks until released
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return probeInFlight(cb) })
+
+	errs := make(chan error)
+	go func() {
+		_, err := cb.Execute(testFunc)
+		errs <- err
+	}()
+
+	// waiting caller gives up after the share timeout
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Millisecond * 100)
+
+	assert.Equal(t, <-errs, errors.New("circuit half open. trying to recover"))
+}
+
+func TestResultOfProbeFuncIsNotShared(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	defer close(release)
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:                1,
+		RetryInterval:            1,
+		HalfOpenShareProbeResult: true,
+		Clock:                    clock,
+		ProbeFunc: func() error {
+			<-release
+			return nil
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return probeInFlight(cb) })
+
+	// rejected right away instead of getting the result of the probe function
+	_, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, errors.New("circuit half open. trying to recover"))
+}
+
+func TestWhenProbeFuncSucceedsStateIsClosed(t *testing.T) {
+	clock := newFakeClock()
+	var probes int32
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock, ProbeFunc: func() error {
+		atomic.AddInt32(&probes, 1)
+		return nil
+	}})
+
+	var executions int32
+	errFunc := func() (interface{}, error) {
+		atomic.AddInt32(&executions, 1)
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	// recovery used the probe instead of the failing function
+	assert.Equal(t, atomic.LoadInt32(&probes), int32(1))
+	assert.Equal(t, atomic.LoadInt32(&executions), int32(2))
+}
+
+func TestHalfOpenEntryErrorResetDecidesHowManyProbesMayFail(t *testing.T) {
+	for reset, probes := range map[int]int{1: 3, 2: 2} {
+		clock := newFakeClock()
+		cb := NewCircuitBreaker("test", &Strategy{
+			Threshold:               3,
+			RetryInterval:           1,
+			RetryMax:                1,
+			HalfOpenEntryErrorReset: reset,
+			Clock:                   clock,
+			Logger:                  &recordingLogger{},
+		})
+
+		for i := 0; i < 4; i++ {
+			cb.Allow()
+			cb.Record(false)
+		}
+		assert.Equal(t, cb.GetState(), HalfOpen)
+
+		// more probes fail than RetryMax allows before the counter trips again
+		failed := 0
+		for cb.GetState() == HalfOpen {
+			assert.Equal(t, cb.Allow(), true)
+			cb.Record(false)
+			failed++
+			clock.Advance(time.Second)
+		}
+		assert.Equal(t, cb.GetState(), Open)
+		assert.Equal(t, failed, probes)
+	}
+}
+
+func TestWhenProbesFailAfterErrorResetStateIsOpen(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 3, RetryInterval: 1, RetryMax: 1, HalfOpenEntryErrorReset: 1, Clock: clock, Logger: &recordingLogger{}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	for i := 0; i < 4; i++ {
+		cb.Execute(errFunc)
+	}
+
+	// two failed probes stay below the threshold
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// third failed probe exceeds it, although RetryMax allows a single retry only
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Open })
+}
+
+func TestOnAdmitCountsClosedExecutionsAndProbes(t *testing.T) {
+	clock := newFakeClock()
+	var mu sync.Mutex
+	admits := make(map[State]int)
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock, OnAdmit: func(name string, state State) {
+		mu.Lock()
+		defer mu.Unlock()
+		admits[state]++
+	}})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// rejected executions are not admitted
+	cb.Execute(testFunc)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, admits, map[State]int{Closed: 2, HalfOpen: 1})
+}
+
+func TestWhenHooksPanicExecuteReturnsNormally(t *testing.T) {
+	var mu sync.Mutex
+	var internalErrors []error
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold: 1,
+		OnAdmit: func(name string, state State) {
+			panic("admit")
+		},
+		IsFailure: func(err error) bool {
+			panic("classify")
+		},
+		OnInternalError: func(name string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			internalErrors = append(internalErrors, err)
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	_, err := cb.Execute(errFunc)
+	assert.Equal(t, err, errors.New("i like to fail"))
+
+	// lock was released and the error still counted as failure
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, internalErrors[0], errors.New("OnAdmit panicked: admit"))
+	assert.Equal(t, internalErrors[1], errors.New("IsFailure panicked: classify"))
+}
+
+func TestWhenProbeFuncPanicsProbeFails(t *testing.T) {
+	clock := newFakeClock()
+	internalErrors := make(chan error, 1)
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		Clock:         clock,
+		ProbeFunc: func() error {
+			panic("probe")
+		},
+		OnInternalError: func(name string, err error) {
+			internalErrors <- err
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+
+	assert.Equal(t, <-internalErrors, errors.New("ProbeFunc panicked: probe"))
+
+	// recovery keeps going after the panic
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+// recordLatencies fills the latency tracker with n samples of d
+func recordLatencies(cb CircuitBreaker, d time.Duration, n int) {
+	c := cb.(*circuitBreaker)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < n; i++ {
+		c.latencies.record(d)
+	}
+}
+
+func shedProbability(cb CircuitBreaker) float64 {
+	c := cb.(*circuitBreaker)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shedProbability()
+}
+
+func TestShedProbabilityRisesWithLatency(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{LoadShedTarget: time.Millisecond * 100})
+
+	recordLatencies(cb, time.Millisecond*80, 100)
+	assert.Equal(t, shedProbability(cb), float64(0))
+
+	recordLatencies(cb, time.Millisecond*125, 100)
+	assert.Equal(t, shedProbability(cb), 0.25)
+
+	recordLatencies(cb, time.Millisecond*150, 100)
+	assert.Equal(t, shedProbability(cb), 0.5)
+
+	recordLatencies(cb, time.Millisecond*500, 100)
+	assert.Equal(t, shedProbability(cb), float64(1))
+}
+
+func TestWhenLatencyExceedsTargetExecutionsAreShed(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{LoadShedTarget: time.Millisecond * 100})
+	cb.(*circuitBreaker).random = func() float64 {
+		return 0.3
+	}
+
+	var executions int32
+	happyFunc := func() (interface{}, error) {
+		atomic.AddInt32(&executions, 1)
+		return "yay", nil
+	}
+
+	// a quarter is shed which misses the random draw
+	recordLatencies(cb, time.Millisecond*125, 100)
+	_, err := cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+
+	// half is shed which hits the random draw
+	recordLatencies(cb, time.Millisecond*150, 100)
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, ErrLoadShed)
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestStatsCountsLatenciesInCustomBuckets(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{LatencyBuckets: []time.Duration{
+		time.Millisecond * 100, time.Millisecond * 10, time.Second,
+	}})
+
+	recordLatencies(cb, time.Millisecond*5, 2)
+	recordLatencies(cb, time.Millisecond*10, 1)
+	recordLatencies(cb, time.Millisecond*50, 3)
+	recordLatencies(cb, time.Millisecond*500, 1)
+	recordLatencies(cb, time.Second*2, 1)
+
+	stats := cb.Stats()
+	assert.Equal(t, stats.Latency, []LatencyBucket{
+		{UpperBound: time.Millisecond * 10, Count: 3},
+		{UpperBound: time.Millisecond * 100, Count: 3},
+		{UpperBound: time.Second, Count: 1},
+	})
+	assert.Equal(t, stats.LatencyCount, uint64(8))
+	assert.Equal(t, stats.LatencySum, time.Millisecond*2670)
+}
+
+func TestWhenIdenticalErrorsRepeatWithinDedupWindowTheyCountOnce(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2, DedupWindow: time.Second, Clock: clock})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	for i := 0; i < 5; i++ {
+		cb.Execute(errFunc)
+		clock.Advance(time.Millisecond * 100)
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+
+	// the same error counts again once the window passed
+	clock.Advance(time.Second)
+	cb.Execute(errFunc)
+	clock.Advance(time.Second)
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestWhenErrorsDifferWithinDedupWindowTheyCountSeparately(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2, DedupWindow: time.Second, Clock: clock})
+
+	for i := 0; i < 3; i++ {
+		err := fmt.Errorf("i like to fail %d", i)
+		cb.Execute(func() (interface{}, error) {
+			return nil, err
+		})
+	}
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestErrorKeyDecidesWhichErrorsAreIdentical(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2, DedupWindow: time.Second, Clock: clock, ErrorKey: func(err error) string {
+		return "same"
+	}})
+
+	for i := 0; i < 3; i++ {
+		err := fmt.Errorf("i like to fail %d", i)
+		cb.Execute(func() (interface{}, error) {
+			return nil, err
+		})
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenForcedHalfOpenOnlyProbeRateIsAdmitted(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{RetryInterval: 1, Clock: clock})
+	cb.ForceHalfOpen("test")
+
+	var executions int32
+	happyFunc := func() (interface{}, error) {
+		atomic.AddInt32(&executions, 1)
+		return "yay", nil
+	}
+
+	res, err := cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, errors.New("circuit half open. trying to recover"))
+
+	clock.Advance(time.Second)
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+
+	// successes do not close the pinned breaker
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, atomic.LoadInt32(&executions), int32(2))
+}
+
+func TestWhenForcedHalfOpenIsResetStateIsClosed(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.ForceHalfOpen("test")
+	cb.Reset("test")
+	assert.Equal(t, cb.GetState(), Closed)
+
+	// failures were cleared with the reset
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenTransitionGuardVetoesTripStateIsClosed(t *testing.T) {
+	logger := &recordingLogger{}
+	var guarded []Counts
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, Logger: logger, TransitionGuard: func(from, to State, counts Counts) bool {
+		guarded = append(guarded, counts)
+		return false
+	}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	_, err := cb.Execute(errFunc)
+
+	assert.Equal(t, err, errors.New("i like to fail"))
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, guarded[0], Counts{Requests: 2, TotalFailures: 2, ConsecutiveFailures: 2})
+	assert.Equal(t, logger.Entries()[0], "WARNING: test circuit breaker transition from closed to half open vetoed")
+}
+
+func TestWhenTransitionGuardAllowsTripStateIsHalfOpen(t *testing.T) {
+	var transitions [][2]State
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, TransitionGuard: func(from, to State, counts Counts) bool {
+		transitions = append(transitions, [2]State{from, to})
+		return true
+	}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, transitions, [][2]State{{Closed, HalfOpen}})
+}
+
+func TestWhenTransitionGuardVetoesCloseBreakerKeepsRecovering(t *testing.T) {
+	clock := newFakeClock()
+	var vetoes int32 = 1
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock, Logger: &recordingLogger{}, TransitionGuard: func(from, to State, counts Counts) bool {
+		return to != Closed || atomic.AddInt32(&vetoes, -1) < 0
+	}})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	atomic.StoreInt32(&failing, 0)
+
+	// first successful probe is vetoed
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+	assert.Equal(t, cb.Counts(), Counts{})
+}
+
+func TestCallsUntilTripCountsDownToTrip(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 2})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	assert.Equal(t, cb.CallsUntilTrip(), 3)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.CallsUntilTrip(), 2)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.CallsUntilTrip(), 1)
+
+	cb.Execute(happyFunc)
+	assert.Equal(t, cb.CallsUntilTrip(), 3)
+
+	for i := 0; i < 3; i++ {
+		cb.Execute(errFunc)
+	}
+	assert.Equal(t, cb.CallsUntilTrip(), 0)
+}
+
+func TestCallsUntilTripIsUnknownForOtherCounters(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{}, WithCounter(NewSlidingWindowCounter(2, time.Minute)))
+
+	assert.Equal(t, cb.CallsUntilTrip(), CallsUntilTripUnknown)
+}
+
+func TestWhenStartingHalfOpenSuccessfulProbesClose(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{RetryInterval: 1, SuccessThreshold: 2, Clock: clock}, WithInitialState(HalfOpen))
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	res, err := cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// only one probe per retry interval
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, errors.New("circuit half open. trying to recover"))
+
+	clock.Advance(time.Second)
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cb.GetState(), Closed)
+
+	_, err = cb.Execute(happyFunc)
+	assert.Equal(t, err, nil)
+}
+
+func TestWhenStartingHalfOpenFailedProbeRestartsSuccessCount(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{RetryInterval: 1, SuccessThreshold: 2, Clock: clock}, WithInitialState(HalfOpen))
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	cb.Execute(happyFunc)
+	clock.Advance(time.Second)
+	cb.Execute(errFunc)
+	clock.Advance(time.Second)
+	cb.Execute(happyFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	cb.Execute(happyFunc)
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenRecoveringSuccessThresholdProbesAreNeeded(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, SuccessThreshold: 2, Clock: clock})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	atomic.StoreInt32(&failing, 0)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+}
+
+func TestWhenPerCallTimeoutIsShorterExecutionTimesOut(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Timeout: time.Second, Clock: clock})
+
+	release := make(chan struct{})
+	defer close(release)
+	blockingFunc := func() (interface{}, error) {
+		<-release
+		return "yay", nil
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := cb.ExecuteWithTimeout(time.Millisecond*100, blockingFunc)
+		errs <- err
+	}()
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Millisecond * 100)
+
+	assert.Equal(t, <-errs, ErrTimeout)
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+}
+
+func TestWhenPerCallTimeoutIsLongerExecutionOutlastsDefault(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Timeout: time.Second, Clock: clock})
+
+	release := make(chan struct{})
+	blockingFunc := func() (interface{}, error) {
+		<-release
+		return "yay", nil
+	}
+
+	type result struct {
+		res interface{}
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		res, err := cb.ExecuteWithTimeout(time.Second*5, blockingFunc)
+		results <- result{res, err}
+	}()
+
+	// the default timeout passes without failing the execution
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second * 2)
+	select {
+	case r := <-results:
+		t.Fatalf("execution finished early with %v", r.err)
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	close(release)
+	r := <-results
+	assert.Equal(t, r.err, nil)
+	assert.Equal(t, r.res, "yay")
+}
+
+func TestWhenDefaultTimeoutPassesExecutionTimesOut(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Timeout: time.Second, Clock: clock})
+
+	release := make(chan struct{})
+	defer close(release)
+	blockingFunc := func() (interface{}, error) {
+		<-release
+		return "yay", nil
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := cb.Execute(blockingFunc)
+		errs <- err
+	}()
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+
+	assert.Equal(t, <-errs, ErrTimeout)
+}
+
+func TestWhenOpenFallbackServesUntilProbesConfirmRecovery(t *testing.T) {
+	clock := newFakeClock()
+	var healthy int32
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		RetryMax:      1,
+		Clock:         clock,
+		Logger:        &recordingLogger{},
+		ProbeFunc: func() error {
+			if atomic.LoadInt32(&healthy) == 1 {
+				return nil
+			}
+			return errors.New("still down")
+		},
+		Fallback: func(err error) (interface{}, error) {
+			return "cached", nil
+		},
+	})
+
+	var executions int32
+	testFunc := func() (interface{}, error) {
+		atomic.AddInt32(&executions, 1)
+		if atomic.LoadInt32(&healthy) == 1 {
+			return "yay", nil
+		}
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// recovering fails and keeps probing in open state
+	for i := 0; i < 3; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), Open)
+
+	res, err := cb.Execute(testFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "cached")
+
+	// real traffic only resumes once a probe confirmed recovery
+	atomic.StoreInt32(&healthy, 1)
+	res, _ = cb.Execute(testFunc)
+	assert.Equal(t, res, "cached")
+	assert.Equal(t, atomic.LoadInt32(&executions), int32(2))
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	res, err = cb.Execute(testFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+}
+
+type statusResponse struct {
+	Code int
+}
+
+func TestWhenResultIsFailureValueBreakerTrips(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, IsFailureValue: func(res interface{}) bool {
+		resp, ok := res.(*statusResponse)
+		return ok && resp.Code == 503
+	}})
+
+	unavailableFunc := func() (interface{}, error) {
+		return &statusResponse{Code: 503}, nil
+	}
+
+	res, err := cb.Execute(unavailableFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, &statusResponse{Code: 503})
+
+	cb.Execute(unavailableFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, cb.Stats().Failures, uint64(2))
+}
+
+func TestWhenResultIsNoFailureValueBreakerStaysClosed(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, IsFailureValue: func(res interface{}) bool {
+		resp, ok := res.(*statusResponse)
+		return ok && resp.Code == 503
+	}})
+
+	okFunc := func() (interface{}, error) {
+		return &statusResponse{Code: 200}, nil
+	}
+
+	cb.Execute(okFunc)
+	cb.Execute(okFunc)
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenProbeHangsItIsAbandonedAtProbeTimeout(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	defer close(release)
+	probeCtx := make(chan context.Context, 1)
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		ProbeTimeout:  time.Millisecond * 500,
+		Clock:         clock,
+		ProbeWithContext: func(ctx context.Context) error {
+			probeCtx <- ctx
+			<-release
+			return nil
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	drain(cb)
+
+	// start the hanging probe
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	ctx := <-probeCtx
+
+	// abandon it at the timeout
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Millisecond * 500)
+
+	// next attempt is scheduled after the failed one
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, ctx.Err() != nil, true)
+
+	events := drain(cb)
+	assert.Equal(t, eventTypes(events), []EventType{EventProbe})
+	assert.Equal(t, events[0].Err, context.DeadlineExceeded)
+}
+
+func TestWhenFallbackIsAsyncExecuteReturnsWithoutWaiting(t *testing.T) {
+	release := make(chan struct{})
+	served := make(chan error, 1)
+	cb := NewCircuitBreaker("test", &Strategy{
+		AsyncFallback: true,
+		Fallback: func(err error) (interface{}, error) {
+			<-release
+			served <- err
+			return "cached", nil
+		},
+	})
+	cb.ForceOpen("test")
+
+	res, err := cb.Execute(func() (interface{}, error) {
+		return "success", nil
+	})
+	assert.Equal(t, res, nil)
+	assert.Equal(t, err, ErrFallbackPending)
+
+	// fallback is still running in the background
+	select {
+	case <-served:
+		t.Fatal("fallback finished before it was released")
+	default:
+	}
+
+	close(release)
+	assert.Equal(t, <-served, errors.New("test circuit breaker open"))
+}
+
+func TestWhenExecutionSucceedsItRunsOnce(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+
+	executions := 0
+	res, err := cb.Execute(func() (interface{}, error) {
+		executions++
+		return executions, nil
+	})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, 1)
+	assert.Equal(t, executions, 1)
+}
+
+func TestWhenDoubleExecuteIsAllowedSuccessRunsTwice(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{AllowDoubleExecute: true})
+
+	executions := 0
+	res, err := cb.Execute(func() (interface{}, error) {
+		executions++
+		return executions, nil
+	})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, 2)
+	assert.Equal(t, executions, 2)
+}
+
+func TestWhenProbeFailuresAreWithinGraceSuccessesAccumulate(t *testing.T) {
+	clock := newFakeClock()
+	outcomes := []error{nil, errors.New("blip"), nil, errors.New("blip"), nil, nil, nil}
+	var probes int32
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:            1,
+		RetryInterval:        1,
+		RetryMax:             10,
+		SuccessThreshold:     3,
+		HalfOpenFailureGrace: 1,
+		Clock:                clock,
+		ProbeFunc: func() error {
+			return outcomes[atomic.AddInt32(&probes, 1)-1]
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	// the first failure is tolerated, the second resets the successes
+	for i := 0; i < 6; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, cb.(*circuitBreaker).probeSuccesses, 2)
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+	assert.Equal(t, atomic.LoadInt32(&probes), int32(7))
+}
+
+func TestWhenProbeSucceedsBelowLatencyFloorItDoesNotCount(t *testing.T) {
+	clock := newFakeClock()
+	var probes int32
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:         1,
+		RetryInterval:     1,
+		RetryMax:          10,
+		SuccessThreshold:  2,
+		MinSuccessLatency: time.Millisecond * 10,
+		Clock:             clock,
+		ProbeFunc: func() error {
+			// the first probes return instantly
+			if atomic.AddInt32(&probes, 1) > 2 {
+				time.Sleep(time.Millisecond * 20)
+			}
+			return nil
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	drain(cb)
+
+	for i := 0; i < 3; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	var flagged int
+	for _, event := range drain(cb) {
+		if event.Type == EventProbe && event.Err == ErrBelowLatencyFloor {
+			flagged++
+		}
+	}
+	assert.Equal(t, flagged, 2)
+}
+
+func TestStatsMeasureRecoveryDuration(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "success", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// recovers with the third probe
+	for i := 0; i < 3; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		if i == 2 {
+			atomic.StoreInt32(&failing, 0)
+		}
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	stats := cb.Stats()
+	assert.Equal(t, stats.Recoveries, uint64(1))
+	assert.Equal(t, stats.LastRecoveryDuration, time.Second*3)
+	assert.Equal(t, stats.AverageRecoveryDuration, time.Second*3)
+
+	// recovers with the first probe
+	atomic.StoreInt32(&failing, 1)
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	atomic.StoreInt32(&failing, 0)
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	stats = cb.Stats()
+	assert.Equal(t, stats.Recoveries, uint64(2))
+	assert.Equal(t, stats.LastRecoveryDuration, time.Second)
+	assert.Equal(t, stats.AverageRecoveryDuration, time.Second*2)
+}
+
+func TestWithInitialStateOnlyStartsInStatesItCanLeave(t *testing.T) {
+	assert.Equal(t, NewCircuitBreaker("test", &Strategy{}, WithInitialState(Open)).GetState(), HalfOpen)
+	assert.Equal(t, NewCircuitBreaker("test", &Strategy{SoftOpenSampleRate: 0.5}, WithInitialState(Open)).GetState(), Open)
+	assert.Equal(t, NewCircuitBreaker("test", &Strategy{ManualRecoveryOnly: true}, WithInitialState(Open)).GetState(), Open)
+	assert.Equal(t, NewCircuitBreaker("test", &Strategy{}, WithInitialState(State(0))).GetState(), Closed)
+	assert.Equal(t, NewCircuitBreaker("test", &Strategy{}, WithInitialState(State(4))).GetState(), Closed)
+
+	// starting open without a way out probes with the first execution
+	cb := NewCircuitBreaker("test", &Strategy{}, WithInitialState(Open))
+	res, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenSoftOpenSampleOfExecutionsIsAdmitted(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{SoftOpenSampleRate: 0.05, Logger: &recordingLogger{}}, WithInitialState(Open))
+	cb.(*circuitBreaker).random = rand.New(rand.NewSource(1)).Float64
+
+	admitted := 0
+	errFunc := func() (interface{}, error) {
+		admitted++
+		return nil, errors.New("i like to fail")
+	}
+
+	for i := 0; i < 2000; i++ {
+		cb.Execute(errFunc)
+	}
+	assert.Equal(t, admitted > 70 && admitted < 130, true)
+	assert.Equal(t, cb.Stats().Requests, uint64(admitted))
+	assert.Equal(t, cb.GetState(), Open)
+
+	// a sampled success closes the circuit breaker
+	for cb.GetState() == Open {
+		cb.Execute(func() (interface{}, error) {
+			return "success", nil
+		})
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestOnCountsResetReceivesCountsBeforeReset(t *testing.T) {
+	var previous []Counts
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5, OnCountsReset: func(name string, counts Counts) {
+		previous = append(previous, counts)
+	}})
+
+	cb.Execute(func() (interface{}, error) {
+		return "success", nil
+	})
+	cb.Execute(func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	})
+	cb.Reset("test")
+
+	assert.Equal(t, previous, []Counts{
+		{Requests: 2, TotalSuccesses: 1, TotalFailures: 1, ConsecutiveFailures: 1},
+	})
+	assert.Equal(t, cb.Counts(), Counts{})
+}
+
+func TestWhenRecoveryIsManualOnlyOpenBreakerStaysOpen(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:          1,
+		RetryInterval:      1,
+		ManualRecoveryOnly: true,
+		SoftOpenSampleRate: 1,
+		Clock:              clock,
+		Logger:             &recordingLogger{},
+		ProbeFunc: func() error {
+			return nil
+		},
+	})
+
+	executions := 0
+	testFunc := func() (interface{}, error) {
+		executions++
+		if executions <= 2 {
+			return nil, errors.New("i like to fail")
+		}
+		return "success", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	assert.Equal(t, cb.GetState(), Open)
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Hour)
+		cb.Execute(testFunc)
+	}
+	assert.Equal(t, cb.GetState(), Open)
+	assert.Equal(t, executions, 2)
+	assert.Equal(t, clock.Pending(), 0)
+
+	cb.Reset("test")
+	res, _ := cb.Execute(testFunc)
+	assert.Equal(t, res, "success")
+}
+
+func TestWhenProbeSuccessesAreWeightedBreakerClosesSooner(t *testing.T) {
+	probesUntilClosed := func(weight func(res interface{}) int) int {
+		clock := newFakeClock()
+		cb := NewCircuitBreaker("test", &Strategy{
+			RetryInterval:    1,
+			SuccessThreshold: 4,
+			SuccessWeight:    weight,
+			Clock:            clock,
+		}, WithInitialState(HalfOpen))
+
+		probes := 0
+		for cb.GetState() != Closed {
+			clock.Advance(time.Second)
+			cb.Execute(func() (interface{}, error) {
+				probes++
+				return statusResponse{Code: 200}, nil
+			})
+		}
+		return probes
+	}
+
+	assert.Equal(t, probesUntilClosed(nil), 4)
+	assert.Equal(t, probesUntilClosed(func(res interface{}) int {
+		if res.(statusResponse).Code == 200 {
+			return 2
+		}
+		return 1
+	}), 2)
+}
+
+func TestExecuteIfClosedRunsOnlyWhenClosed(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	res, err, admitted := cb.ExecuteIfClosed(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, admitted, true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+
+	cb.ForceOpen("alice")
+	ran := false
+	_, err, admitted = cb.ExecuteIfClosed(func() (interface{}, error) {
+		ran = true
+		return "yay", nil
+	})
+	assert.Equal(t, admitted, false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ran, false)
+}
+
+func TestWhenExecuteIfClosedTripsFunctionIsNeverRetried(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	var executions int32
+	errFunc := func() (interface{}, error) {
+		atomic.AddInt32(&executions, 1)
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.ExecuteIfClosed(errFunc)
+	cb.ExecuteIfClosed(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second * 10)
+	assert.Equal(t, clock.Pending(), 0)
+	assert.Equal(t, atomic.LoadInt32(&executions), int32(2))
+}
+
+func TestExecuteIfClosedDoesNotProbeWhenHalfOpen(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{RetryInterval: 1, Clock: clock}, WithInitialState(HalfOpen))
+
+	ran := false
+	_, err, admitted := cb.ExecuteIfClosed(func() (interface{}, error) {
+		ran = true
+		return "yay", nil
+	})
+	assert.Equal(t, admitted, false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ran, false)
+	assert.Equal(t, cb.Stats().Rejections, uint64(1))
+
+	// the probe which would have been admitted is left for Execute
+	res, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestFailureDuringPostRecoveryProbationTripsAtProbationThreshold(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:             3,
+		RetryInterval:         1,
+		PostRecoveryProbation: 10 * time.Second,
+		Clock:                 clock,
+	})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "success", nil
+	}
+	recoverBreaker := func() {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		atomic.StoreInt32(&failing, 0)
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return cb.GetState() == Closed })
+		atomic.StoreInt32(&failing, 1)
+	}
+
+	for i := 0; i < 4; i++ {
+		cb.Execute(testFunc)
+	}
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	recoverBreaker()
+
+	// the first failure after recovering trips again
+	cb.Execute(testFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, cb.LastTripEvaluation().Threshold, float64(0))
+	recoverBreaker()
+
+	// after the probation the threshold applies again
+	clock.Advance(10 * time.Second)
+	cb.Execute(testFunc)
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, cb.LastTripEvaluation().Threshold, float64(3))
+}
+
+func TestProbationThresholdCanExceedThreshold(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:             1,
+		PostRecoveryProbation: time.Minute,
+		ProbationThreshold:    3,
+		Clock:                 clock,
+	}, WithInitialState(HalfOpen))
+
+	cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, cb.GetState(), Closed)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	for i := 0; i < 3; i++ {
+		cb.Execute(errFunc)
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestCallsUntilTripAndWouldTripApplyProbationThreshold(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:             5,
+		PostRecoveryProbation: time.Minute,
+		ProbationThreshold:    1,
+		Clock:                 clock,
+	}, WithInitialState(HalfOpen))
+
+	cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, cb.CallsUntilTrip(), 2)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), false)
+
+	cb.Execute(func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	})
+	assert.Equal(t, cb.CallsUntilTrip(), 1)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), true)
+
+	// after the probation the threshold applies again
+	clock.Advance(time.Minute)
+	assert.Equal(t, cb.CallsUntilTrip(), 5)
+	assert.Equal(t, cb.WouldTrip(errors.New("i like to fail")), false)
+}
+
+func TestConnectErrorsOnlyCountWhenConfigured(t *testing.T) {
+	connectFunc := func() (interface{}, error) {
+		return nil, fmt.Errorf("%w: dial tcp: connection refused", ErrConnect)
+	}
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+	for i := 0; i < 3; i++ {
+		_, err := cb.Execute(connectFunc)
+		assert.Equal(t, errors.Is(err, ErrConnect), true)
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, cb.Counts().TotalFailures, uint64(0))
+	assert.Equal(t, cb.WouldTrip(fmt.Errorf("%w: timeout", ErrConnect)), false)
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	cb = NewCircuitBreaker("test", &Strategy{Threshold: 1, ConnectErrorsCount: true})
+	cb.Execute(connectFunc)
+	cb.Execute(connectFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestConnectErrorsDoNotCountAsSuccessfulProbes(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{RetryInterval: 1}, WithInitialState(HalfOpen))
+
+	cb.Execute(func() (interface{}, error) {
+		return nil, fmt.Errorf("%w: no route to host", ErrConnect)
+	})
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestNeutralWrappedErrorsDoNotCount(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	for i := 0; i < 3; i++ {
+		_, err := cb.Execute(func() (interface{}, error) {
+			return nil, Neutral(errors.New("not found"))
+		})
+		assert.Equal(t, err, errors.New("not found"))
+	}
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, cb.Counts().TotalFailures, uint64(0))
+	assert.Equal(t, cb.Counts().TotalSuccesses, uint64(0))
+}
+
+func TestTripWrappedErrorsCountEvenWhenIgnoredByClassifier(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, IsFailure: func(err error) bool {
+		return false
+	}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.Counts().TotalFailures, uint64(0))
+
+	for i := 0; i < 2; i++ {
+		_, err := cb.Execute(func() (interface{}, error) {
+			return nil, Trip(errors.New("corrupt response"))
+		})
+		assert.Equal(t, err, errors.New("corrupt response"))
+	}
+	assert.Equal(t, cb.Counts().TotalFailures, uint64(2))
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestBackgroundProbesApplyClassification(t *testing.T) {
+	notFound := errors.New("not found")
+	outcomes := []error{errors.New("i like to fail"), errors.New("i like to fail"), Neutral(errors.New("cancelled")), notFound}
+	for _, strategy := range []*Strategy{
+		{},
+		{IsFailure: func(err error) bool { return err != notFound }},
+	} {
+		clock := newFakeClock()
+		strategy.Threshold, strategy.RetryInterval, strategy.RetryMax, strategy.Clock = 1, 1, 1, clock
+		cb := NewCircuitBreaker("test", strategy)
+
+		var calls int32
+		testFunc := func() (interface{}, error) {
+			return nil, outcomes[atomic.AddInt32(&calls, 1)-1]
+		}
+		cb.Execute(testFunc)
+		cb.Execute(testFunc)
+		assert.Equal(t, cb.GetState(), HalfOpen)
+
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 3 && clock.Pending() == 1 })
+		assert.Equal(t, cb.GetState(), HalfOpen)
+
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 4 })
+		if strategy.IsFailure == nil {
+			// only the failed probe counts as retry
+			waitFor(t, func() bool { return clock.Pending() == 1 })
+			assert.Equal(t, cb.GetState(), HalfOpen)
+		} else {
+			waitFor(t, func() bool { return cb.GetState() == Closed })
+		}
+	}
+}
+
+func TestSharedProbeResultIsUnwrapped(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	var calls int32
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:                1,
+		RetryInterval:            1,
+		HalfOpenShareProbeResult: true,
+		HalfOpenShareTimeout:     time.Minute,
+		Clock:                    clock,
+	})
+
+	testFunc := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, errors.New("i like to fail")
+		}
+		<-release
+		return nil, Trip(errors.New("corrupt response"))
+	}
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 3 })
+
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	_, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, errors.New("corrupt response"))
+}
@@ -0,0 +1,35 @@
+package go_circuit_breaker
+
+// errorCategory assigns err to a category using the strategy, empty without classifier
+func (c *circuitBreaker) errorCategory(err error) string {
+	if c.strategy.ErrorCategory == nil || len(c.strategy.CategoryThresholds) == 0 {
+		return ""
+	}
+
+	category := ""
+	c.guard("ErrorCategory", func() {
+		category = c.strategy.ErrorCategory(err)
+	})
+	return category
+}
+
+// recordCategory counts a failure of category when it has a threshold. Must be called with the lock held
+func (c *circuitBreaker) recordCategory(category string) {
+	if _, ok := c.strategy.CategoryThresholds[category]; !ok {
+		return
+	}
+	if c.categoryFailures == nil {
+		c.categoryFailures = make(map[string]int)
+	}
+	c.categoryFailures[category]++
+}
+
+// trippedCategory returns a category whose failures exceed its threshold. Must be called with the lock held
+func (c *circuitBreaker) trippedCategory() (string, bool) {
+	for category, failures := range c.categoryFailures {
+		if failures > c.strategy.CategoryThresholds[category] {
+			return category, true
+		}
+	}
+	return "", false
+}
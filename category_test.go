@@ -0,0 +1,66 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+)
+
+var (
+	errTimeout     = errors.New("timeout")
+	errServerError = errors.New("server error")
+)
+
+func categoryStrategy() *Strategy {
+	return &Strategy{
+		Threshold:          100,
+		CategoryThresholds: map[string]int{"timeout": 2, "5xx": 5},
+		ErrorCategory: func(err error) string {
+			if err == errTimeout {
+				return "timeout"
+			}
+			return "5xx"
+		},
+	}
+}
+
+func TestWhenCategoryExceedsItsThresholdBreakerTrips(t *testing.T) {
+	cb := NewCircuitBreaker("test", categoryStrategy())
+
+	fail := func(err error) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		cb.Execute(fail(errServerError))
+	}
+	cb.Execute(fail(errTimeout))
+	cb.Execute(fail(errTimeout))
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, cb.WouldTrip(errTimeout), true)
+	assert.Equal(t, cb.WouldTrip(errServerError), false)
+
+	// timeouts alone trip while 5xx stay under their threshold
+	cb.Execute(fail(errTimeout))
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, cb.LastTripEvaluation().Category, "timeout")
+}
+
+func TestSuccessResetsCategoryFailures(t *testing.T) {
+	cb := NewCircuitBreaker("test", categoryStrategy())
+
+	timeout := func() (interface{}, error) {
+		return nil, errTimeout
+	}
+
+	cb.Execute(timeout)
+	cb.Execute(timeout)
+	cb.Execute(func() (interface{}, error) {
+		return "success", nil
+	})
+	cb.Execute(timeout)
+	cb.Execute(timeout)
+	assert.Equal(t, cb.GetState(), Closed)
+}
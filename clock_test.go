@@ -0,0 +1,96 @@
+package go_circuit_breaker
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves forward when advanced by the test
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+	f  func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(&fakeTimer{ch: ch}, d)
+	return ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) {
+	c.schedule(&fakeTimer{f: f}, d)
+}
+
+func (c *fakeClock) schedule(timer *fakeTimer, d time.Duration) {
+	c.mu.Lock()
+	timer.at = c.now.Add(d)
+	c.timers = append(c.timers, timer)
+	c.mu.Unlock()
+
+	if d <= 0 {
+		c.Advance(0)
+	}
+}
+
+// Pending returns the number of timers which did not fire yet
+func (c *fakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward and fires all due timers in order
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+
+	var due []*fakeTimer
+	for len(c.timers) > 0 && !c.timers[0].at.After(c.now) {
+		due = append(due, c.timers[0])
+		c.timers = c.timers[1:]
+	}
+	now := c.now
+	c.mu.Unlock()
+
+	for _, timer := range due {
+		if timer.f != nil {
+			timer.f()
+		} else {
+			timer.ch <- now
+		}
+	}
+}
+
+// waitFor polls cond until it holds or fails the test after a second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
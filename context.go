@@ -0,0 +1,129 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoRegistry is returned when executing by name without a registry in the context
+var ErrNoRegistry = errors.New("no circuit breaker registry in context")
+
+// OpenError is returned by ExecuteWithContext when the circuit breaker rejects an execution
+type OpenError struct {
+	Name  string
+	State State
+	// CorrelationID is read from the context under the strategy's CorrelationIDKey
+	CorrelationID string
+	// RetryAfter is how long until retrying may succeed, bounded by the context deadline. In HalfOpen that is
+	// the next probe, in Open the earliest close after the probes still needed for SuccessThreshold.
+	// Zero when the circuit breaker does not probe on its own and there is no deadline
+	RetryAfter time.Duration
+	// DeadlineBound is set when the context deadline comes before the next probe
+	DeadlineBound bool
+
+	err error
+}
+
+func (e *OpenError) Error() string {
+	if e.CorrelationID == "" {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%v (correlation id %v)", e.err, e.CorrelationID)
+}
+
+func (e *OpenError) Unwrap() error {
+	return e.err
+}
+
+type registryKey struct{}
+
+// WithRegistry returns a copy of ctx carrying the registry
+func WithRegistry(ctx context.Context, reg *Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, reg)
+}
+
+// RegistryFromContext returns the registry carried by ctx
+func RegistryFromContext(ctx context.Context) (*Registry, bool) {
+	reg, ok := ctx.Value(registryKey{}).(*Registry)
+	return reg, ok && reg != nil
+}
+
+// ExecuteCtx executes a function wrapped in the circuit breaker for name of the registry carried by ctx
+func ExecuteCtx(ctx context.Context, name string, f func() (interface{}, error)) (interface{}, error) {
+	reg, ok := RegistryFromContext(ctx)
+	if !ok {
+		return nil, ErrNoRegistry
+	}
+	return reg.Get(name).Execute(f)
+}
+
+type cancelOnTripKey struct{}
+
+// WithCancelOnTrip returns a copy of ctx making ExecuteWithContext call cancel when the circuit breaker
+// trips during the execution. Sharing ctx in a fan out cancels the sibling calls likely to fail as well
+func WithCancelOnTrip(ctx context.Context, cancel context.CancelFunc) context.Context {
+	return context.WithValue(ctx, cancelOnTripKey{}, cancel)
+}
+
+// ExecuteWithContext executes a function wrapped in a circuit breaker pattern, passing ctx on to it.
+// Returns the context error without executing when ctx is already done or ends while waiting for the
+// semaphore and *OpenError when rejected. Fails with ErrTimeout after the strategy's timeout and cancels
+// the context passed to the function. As the function is bound to ctx it is never replayed: a tripped circuit
+// breaker recovers with its probe function or by admitting the next executions as probes
+func (c *circuitBreaker) ExecuteWithContext(ctx context.Context, f func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.semaphore != nil {
+		if err := c.semaphore.Acquire(ctx, c.semaphoreWeight); err != nil {
+			return nil, err
+		}
+		defer c.semaphore.Release(c.semaphoreWeight)
+	}
+
+	cancel, _ := ctx.Value(cancelOnTripKey{}).(context.CancelFunc)
+	trips := c.tripCount()
+	fctx, stop := context.WithCancel(ctx)
+	defer stop()
+	res, err := c.execute(c.timed(c.strategy.Timeout, func() (interface{}, error) {
+		return f(fctx)
+	}), false)
+	if cancel != nil && c.tripCount() != trips {
+		cancel()
+	}
+
+	if openErr, ok := err.(*OpenError); ok {
+		c.annotate(ctx, openErr)
+		return c.fallback(openErr)
+	}
+	return res, err
+}
+
+// annotate bounds the retry after of a rejection by the context deadline and adds the correlation id
+func (c *circuitBreaker) annotate(ctx context.Context, openErr *OpenError) {
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		if openErr.RetryAfter == 0 || remaining < openErr.RetryAfter {
+			openErr.RetryAfter, openErr.DeadlineBound = remaining, true
+		}
+	}
+	if c.strategy.CorrelationIDKey != nil {
+		if id := ctx.Value(c.strategy.CorrelationIDKey); id != nil {
+			openErr.CorrelationID = fmt.Sprint(id)
+		}
+	}
+}
+
+// tripCount returns how often the circuit breaker tripped
+func (c *circuitBreaker) tripCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.trips
+}
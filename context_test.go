@@ -0,0 +1,207 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestExecuteCtxUsesRegistryFromContext(t *testing.T) {
+	reg := NewRegistry(&Strategy{Threshold: 1})
+	ctx := WithRegistry(context.Background(), reg)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	res, err := ExecuteCtx(ctx, "stable", happyFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+
+	ExecuteCtx(ctx, "flaky", errFunc)
+	ExecuteCtx(ctx, "flaky", errFunc)
+	assert.Equal(t, reg.Get("flaky").GetState(), HalfOpen)
+	assert.Equal(t, reg.Get("stable").GetState(), Closed)
+}
+
+func TestExecuteCtxWithoutRegistryFails(t *testing.T) {
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	_, err := ExecuteCtx(context.Background(), "test", happyFunc)
+	assert.Equal(t, err, ErrNoRegistry)
+}
+
+type correlationIDKey struct{}
+
+func TestWhenRejectedOpenErrorCarriesCorrelationID(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{CorrelationIDKey: correlationIDKey{}, Logger: &recordingLogger{}})
+	cb.(*circuitBreaker).state = Open
+
+	happyFunc := func(ctx context.Context) (interface{}, error) {
+		return "yay", nil
+	}
+
+	ctx := context.WithValue(context.Background(), correlationIDKey{}, "req-42")
+	_, err := cb.ExecuteWithContext(ctx, happyFunc)
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.CorrelationID, "req-42")
+	assert.Equal(t, openErr.State, Open)
+	assert.Equal(t, err.Error(), "test circuit breaker open (correlation id req-42)")
+}
+
+func TestWhenContextHasNoCorrelationIDOpenErrorHasNone(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{CorrelationIDKey: correlationIDKey{}, Logger: &recordingLogger{}})
+	cb.(*circuitBreaker).state = Open
+
+	happyFunc := func(ctx context.Context) (interface{}, error) {
+		return "yay", nil
+	}
+
+	_, err := cb.ExecuteWithContext(context.Background(), happyFunc)
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.CorrelationID, "")
+	assert.Equal(t, err.Error(), "test circuit breaker open")
+}
+
+func TestExecuteWithContextPassesContextOn(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+
+	ctx := context.WithValue(context.Background(), correlationIDKey{}, "req-42")
+	res, err := cb.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(correlationIDKey{}), nil
+	})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "req-42")
+}
+
+func TestExecuteWithContextTimesOutAndCancelsContext(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Timeout: time.Second, Clock: clock})
+
+	cancelled := make(chan error, 1)
+	go func() {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}()
+	_, err := cb.ExecuteWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+
+	assert.Equal(t, err, ErrTimeout)
+	assert.Equal(t, <-cancelled, context.Canceled)
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+}
+
+func TestWhenContextDeadlineComesFirstItBoundsRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 60, Clock: clock})
+
+	errFunc := func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	// the third execution probes the tripped dependency
+	cb.ExecuteWithContext(context.Background(), errFunc)
+	cb.ExecuteWithContext(context.Background(), errFunc)
+	cb.ExecuteWithContext(context.Background(), errFunc)
+
+	var openErr *OpenError
+	_, err := cb.ExecuteWithContext(context.Background(), errFunc)
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.RetryAfter, time.Minute)
+	assert.Equal(t, openErr.DeadlineBound, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	_, err = cb.ExecuteWithContext(ctx, errFunc)
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.RetryAfter > 0 && openErr.RetryAfter <= time.Second*2, true)
+	assert.Equal(t, openErr.DeadlineBound, true)
+
+	// a deadline after the next probe does not bind
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	_, err = cb.ExecuteWithContext(ctx, errFunc)
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.RetryAfter, time.Minute)
+	assert.Equal(t, openErr.DeadlineBound, false)
+}
+
+func TestWhenContextExecutionTripsItIsNeverReplayedAfterTheContextEnded(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	executions := 0
+	errFunc := func(ctx context.Context) (interface{}, error) {
+		executions++
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.New("i like to fail")
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cb.ExecuteWithContext(ctx, errFunc)
+		cancel()
+	}
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, clock.Pending(), 0)
+	assert.Equal(t, executions, 2)
+
+	// the next execution probes with its own live context
+	res, err := cb.ExecuteWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return "yay", ctx.Err()
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, executions, 2)
+}
+
+func TestWhenCallTripsBreakerSiblingsAreCancelled(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = WithCancelOnTrip(ctx, cancel)
+
+	siblingErr := make(chan error, 1)
+	started := make(chan struct{})
+	go func() {
+		_, err := cb.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		siblingErr <- err
+	}()
+	<-started
+
+	errFunc := func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.ExecuteWithContext(ctx, errFunc)
+	assert.Equal(t, ctx.Err(), nil)
+
+	cb.ExecuteWithContext(ctx, errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, <-siblingErr, context.Canceled)
+}
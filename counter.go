@@ -0,0 +1,240 @@
+package go_circuit_breaker
+
+import (
+	"math"
+	"time"
+)
+
+// FailureCounter records execution outcomes and decides when the circuit breaker trips
+type FailureCounter interface {
+	Record(success bool, at time.Time)
+	ShouldTrip() bool
+	Reset()
+}
+
+// TripPredictor is implemented by failure counters able to tell whether one more failure trips them
+type TripPredictor interface {
+	WouldTrip(at time.Time) bool
+}
+
+// TripCriteria is implemented by failure counters able to describe their trip condition.
+// The threshold is a failure count or rate depending on the counter and the window zero without one
+type TripCriteria interface {
+	Criteria() (threshold float64, window time.Duration)
+}
+
+// consecutiveCounter trips when more than threshold executions failed in a row
+type consecutiveCounter struct {
+	threshold int
+	failures  int
+}
+
+// NewConsecutiveCounter returns a counter tripping after more than threshold consecutive failures
+func NewConsecutiveCounter(threshold int) FailureCounter {
+	return &consecutiveCounter{threshold: threshold}
+}
+
+func (c *consecutiveCounter) Record(success bool, at time.Time) {
+	if success {
+		c.failures = 0
+		return
+	}
+	c.failures++
+}
+
+func (c *consecutiveCounter) ShouldTrip() bool {
+	return c.failures > c.threshold
+}
+
+func (c *consecutiveCounter) WouldTrip(at time.Time) bool {
+	return c.failures+1 > c.threshold
+}
+
+func (c *consecutiveCounter) Criteria() (float64, time.Duration) {
+	return float64(c.threshold), 0
+}
+
+func (c *consecutiveCounter) Reset() {
+	c.failures = 0
+}
+
+// slidingWindowCounter trips when more than threshold executions failed within the window
+type slidingWindowCounter struct {
+	threshold int
+	window    time.Duration
+	failures  []time.Time
+}
+
+// NewSlidingWindowCounter returns a counter tripping after more than threshold failures within window
+func NewSlidingWindowCounter(threshold int, window time.Duration) FailureCounter {
+	return &slidingWindowCounter{threshold: threshold, window: window}
+}
+
+func (c *slidingWindowCounter) Record(success bool, at time.Time) {
+	if !success {
+		c.failures = append(c.failures, at)
+	}
+
+	// drop failures which left the window
+	cutoff := at.Add(-c.window)
+	for len(c.failures) > 0 && !c.failures[0].After(cutoff) {
+		c.failures = c.failures[1:]
+	}
+}
+
+func (c *slidingWindowCounter) ShouldTrip() bool {
+	return len(c.failures) > c.threshold
+}
+
+func (c *slidingWindowCounter) WouldTrip(at time.Time) bool {
+	cutoff := at.Add(-c.window)
+	failures := 1
+	for _, failedAt := range c.failures {
+		if failedAt.After(cutoff) {
+			failures++
+		}
+	}
+	return failures > c.threshold
+}
+
+func (c *slidingWindowCounter) Criteria() (float64, time.Duration) {
+	return float64(c.threshold), c.window
+}
+
+func (c *slidingWindowCounter) Reset() {
+	c.failures = nil
+}
+
+// rateCounter trips when the failure rate within the window reaches rate
+type rateCounter struct {
+	rate        float64
+	minRequests int
+	window      time.Duration
+	outcomes    []outcome
+	failures    int
+}
+
+type outcome struct {
+	success bool
+	at      time.Time
+}
+
+// NewRateCounter returns a counter tripping when at least rate of the executions within window failed.
+// The rate is only evaluated once minRequests executions were recorded within the window
+func NewRateCounter(rate float64, minRequests int, window time.Duration) FailureCounter {
+	return &rateCounter{rate: rate, minRequests: minRequests, window: window}
+}
+
+func (c *rateCounter) Record(success bool, at time.Time) {
+	c.outcomes = append(c.outcomes, outcome{success: success, at: at})
+	if !success {
+		c.failures++
+	}
+
+	// drop outcomes which left the window
+	cutoff := at.Add(-c.window)
+	for len(c.outcomes) > 0 && !c.outcomes[0].at.After(cutoff) {
+		if !c.outcomes[0].success {
+			c.failures--
+		}
+		c.outcomes = c.outcomes[1:]
+	}
+}
+
+func (c *rateCounter) ShouldTrip() bool {
+	if len(c.outcomes) == 0 || len(c.outcomes) < c.minRequests {
+		return false
+	}
+	return float64(c.failures)/float64(len(c.outcomes)) >= c.rate
+}
+
+func (c *rateCounter) WouldTrip(at time.Time) bool {
+	cutoff := at.Add(-c.window)
+	requests, failures := 1, 1
+	for _, o := range c.outcomes {
+		if o.at.After(cutoff) {
+			requests++
+			if !o.success {
+				failures++
+			}
+		}
+	}
+	if requests < c.minRequests {
+		return false
+	}
+	return float64(failures)/float64(requests) >= c.rate
+}
+
+func (c *rateCounter) Criteria() (float64, time.Duration) {
+	return c.rate, c.window
+}
+
+func (c *rateCounter) Reset() {
+	c.outcomes = nil
+	c.failures = 0
+}
+
+// ewmaCounter trips when the exponentially weighted failure rate exceeds rate.
+// Outcomes lose half of their weight every half life
+type ewmaCounter struct {
+	rate        float64
+	minRequests float64
+	halfLife    time.Duration
+	requests    float64
+	failures    float64
+	last        time.Time
+}
+
+// NewEWMACounter returns a counter tripping when the exponentially weighted failure rate exceeds rate.
+// The rate is only evaluated once the weighted executions reach minRequests
+func NewEWMACounter(rate float64, minRequests int, halfLife time.Duration) FailureCounter {
+	return &ewmaCounter{rate: rate, minRequests: float64(minRequests), halfLife: halfLife}
+}
+
+// decayed returns the weighted requests and failures at the given time
+func (c *ewmaCounter) decayed(at time.Time) (float64, float64) {
+	if c.last.IsZero() || !at.After(c.last) || c.halfLife <= 0 {
+		return c.requests, c.failures
+	}
+	decay := math.Pow(0.5, float64(at.Sub(c.last))/float64(c.halfLife))
+	return c.requests * decay, c.failures * decay
+}
+
+func (c *ewmaCounter) Record(success bool, at time.Time) {
+	c.requests, c.failures = c.decayed(at)
+	if at.After(c.last) {
+		c.last = at
+	}
+
+	c.requests++
+	if !success {
+		c.failures++
+	}
+}
+
+func (c *ewmaCounter) ShouldTrip() bool {
+	return c.exceeds(c.requests, c.failures)
+}
+
+func (c *ewmaCounter) WouldTrip(at time.Time) bool {
+	requests, failures := c.decayed(at)
+	return c.exceeds(requests+1, failures+1)
+}
+
+// exceeds reports whether the failure rate exceeds the trip rate
+func (c *ewmaCounter) exceeds(requests, failures float64) bool {
+	if requests == 0 || requests < c.minRequests {
+		return false
+	}
+	return failures/requests > c.rate
+}
+
+func (c *ewmaCounter) Criteria() (float64, time.Duration) {
+	return c.rate, c.halfLife
+}
+
+func (c *ewmaCounter) Reset() {
+	c.requests = 0
+	c.failures = 0
+	c.last = time.Time{}
+}
@@ -0,0 +1,256 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+// totalCounter trips once a total number of failures was recorded, no matter the successes in between
+type totalCounter struct {
+	limit    int
+	failures int
+}
+
+func (c *totalCounter) Record(success bool, at time.Time) {
+	if !success {
+		c.failures++
+	}
+}
+
+func (c *totalCounter) ShouldTrip() bool {
+	return c.failures >= c.limit
+}
+
+func (c *totalCounter) Reset() {
+	c.failures = 0
+}
+
+func TestWhenCustomCounterTripsStateIsHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5}, WithCounter(&totalCounter{limit: 3}))
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(happyFunc)
+	cb.Execute(errFunc)
+	cb.Execute(happyFunc)
+	assert.Equal(t, cb.GetState(), Closed)
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
+
+func TestConsecutiveCounterTripsAboveThreshold(t *testing.T) {
+	counter := NewConsecutiveCounter(2)
+	now := time.Now()
+
+	counter.Record(false, now)
+	counter.Record(false, now)
+	counter.Record(true, now)
+	counter.Record(false, now)
+	counter.Record(false, now)
+	assert.Equal(t, counter.ShouldTrip(), false)
+
+	counter.Record(false, now)
+	assert.Equal(t, counter.ShouldTrip(), true)
+
+	counter.Reset()
+	assert.Equal(t, counter.ShouldTrip(), false)
+}
+
+func TestSlidingWindowCounterForgetsOldFailures(t *testing.T) {
+	counter := NewSlidingWindowCounter(2, time.Minute)
+	now := time.Now()
+
+	counter.Record(false, now)
+	counter.Record(false, now.Add(time.Second*30))
+	counter.Record(false, now.Add(time.Second*61))
+	assert.Equal(t, counter.ShouldTrip(), false)
+
+	counter.Record(true, now.Add(time.Second*62))
+	counter.Record(false, now.Add(time.Second*63))
+	assert.Equal(t, counter.ShouldTrip(), true)
+}
+
+func TestRateCounterTripsOnFailureRate(t *testing.T) {
+	counter := NewRateCounter(0.5, 4, time.Minute)
+	now := time.Now()
+
+	counter.Record(false, now)
+	counter.Record(false, now)
+	counter.Record(true, now)
+	assert.Equal(t, counter.ShouldTrip(), false)
+
+	counter.Record(true, now)
+	assert.Equal(t, counter.ShouldTrip(), true)
+
+	// failures leave the window
+	counter.Record(true, now.Add(time.Minute))
+	assert.Equal(t, counter.ShouldTrip(), false)
+}
+
+func TestSlidingWindowCounterPredictsTrip(t *testing.T) {
+	counter := NewSlidingWindowCounter(1, time.Minute)
+	now := time.Now()
+
+	counter.Record(false, now)
+	assert.Equal(t, counter.(TripPredictor).WouldTrip(now.Add(time.Second)), true)
+	assert.Equal(t, counter.(TripPredictor).WouldTrip(now.Add(time.Minute)), false)
+}
+
+func TestEWMACounterTripsOnDecayedFailureRate(t *testing.T) {
+	counter := NewEWMACounter(0.5, 3, time.Second*10)
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		counter.Record(true, now)
+	}
+
+	// successes lost half of their weight, so a burst of failures outweighs them sooner
+	later := now.Add(time.Second * 10)
+	counter.Record(false, later)
+	counter.Record(false, later)
+	assert.Equal(t, counter.ShouldTrip(), false)
+	assert.Equal(t, counter.(TripPredictor).WouldTrip(later), true)
+
+	counter.Record(false, later)
+	assert.Equal(t, counter.ShouldTrip(), true)
+}
+
+func TestEWMACounterForgetsOldFailuresGradually(t *testing.T) {
+	counter := NewEWMACounter(0.5, 1, time.Second*10)
+	now := time.Now()
+
+	counter.Record(false, now)
+	counter.Record(false, now)
+	counter.Record(false, now)
+	assert.Equal(t, counter.ShouldTrip(), true)
+
+	// one half life is not enough to forget the failures
+	counter.Record(true, now.Add(time.Second*10))
+	assert.Equal(t, counter.ShouldTrip(), true)
+
+	counter.Record(true, now.Add(time.Second*40))
+	assert.Equal(t, counter.ShouldTrip(), false)
+}
+
+func TestEvaluateTripTripsOnInjectedCounts(t *testing.T) {
+	counter := &totalCounter{limit: 3, failures: 2}
+	cb := NewCircuitBreaker("test", &Strategy{}, WithCounter(counter))
+
+	assert.Equal(t, cb.EvaluateTrip(), false)
+	assert.Equal(t, cb.GetState(), Closed)
+
+	cb.(*circuitBreaker).mu.Lock()
+	counter.failures = 3
+	cb.(*circuitBreaker).mu.Unlock()
+
+	assert.Equal(t, cb.EvaluateTrip(), true)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, cb.Counts().Requests, uint64(0))
+}
+
+func TestFailedProbesCountInWindowOnlyWhenConfigured(t *testing.T) {
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	for _, count := range []bool{false, true} {
+		counter := &totalCounter{limit: 100}
+		cb := NewCircuitBreaker("test", &Strategy{
+			RetryInterval:              1,
+			RetryMax:                   5,
+			CountProbeFailuresInWindow: count,
+		}, WithInitialState(HalfOpen), WithCounter(counter))
+
+		cb.Execute(errFunc)
+		assert.Equal(t, cb.GetState(), HalfOpen)
+		if count {
+			assert.Equal(t, counter.failures, 1)
+		} else {
+			assert.Equal(t, counter.failures, 0)
+		}
+	}
+}
+
+func TestFailedProbesCountTowardFirstTripAfterRecoveryWhenConfigured(t *testing.T) {
+	for _, count := range []bool{false, true} {
+		clock := newFakeClock()
+		cb := NewCircuitBreaker("test", &Strategy{
+			RetryInterval:              1,
+			Clock:                      clock,
+			CountProbeFailuresInWindow: count,
+		}, WithCounter(NewRateCounter(0.5, 4, time.Minute)))
+		report := func(success bool) {
+			assert.Equal(t, cb.Allow(), true)
+			cb.Record(success)
+		}
+
+		for i := 0; i < 4; i++ {
+			report(false)
+		}
+		assert.Equal(t, cb.GetState(), HalfOpen)
+
+		clock.Advance(time.Second * 30)
+		report(false)
+		clock.Advance(time.Second)
+		report(true)
+		assert.Equal(t, cb.GetState(), Closed)
+
+		// the failures before the trip left the window, the failed probe did not
+		clock.Advance(time.Second * 30)
+		for i := 0; i < 3; i++ {
+			report(false)
+		}
+		if count {
+			assert.Equal(t, cb.GetState(), HalfOpen)
+		} else {
+			assert.Equal(t, cb.GetState(), Closed)
+		}
+	}
+}
+
+func TestFailedBackgroundProbesCountInWindowWhenConfigured(t *testing.T) {
+	for _, count := range []bool{false, true} {
+		clock := newFakeClock()
+		counter := &totalCounter{limit: 1}
+		cb := NewCircuitBreaker("test", &Strategy{
+			RetryInterval:              1,
+			RetryMax:                   5,
+			Clock:                      clock,
+			CountProbeFailuresInWindow: count,
+			ProbeFunc: func() error {
+				return errors.New("still down")
+			},
+		}, WithCounter(counter))
+
+		cb.Execute(func() (interface{}, error) {
+			return nil, errors.New("i like to fail")
+		})
+		assert.Equal(t, cb.GetState(), HalfOpen)
+
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+
+		c := cb.(*circuitBreaker)
+		c.mu.Lock()
+		failures := counter.failures
+		c.mu.Unlock()
+		if count {
+			assert.Equal(t, failures, 2)
+		} else {
+			assert.Equal(t, failures, 1)
+		}
+		assert.Equal(t, cb.GetState(), HalfOpen)
+		cb.Stop()
+	}
+}
@@ -0,0 +1,78 @@
+package go_circuit_breaker
+
+import (
+	"math"
+	"time"
+)
+
+const defaultErrorRateWindow = time.Minute
+
+// outcomeBuckets counts execution outcomes in one second buckets over a rolling window
+type outcomeBuckets struct {
+	buckets []outcomeBucket
+}
+
+type outcomeBucket struct {
+	second    int64
+	successes uint64
+	failures  uint64
+}
+
+// newOutcomeBuckets returns buckets covering window, at least one second
+func newOutcomeBuckets(window time.Duration) outcomeBuckets {
+	n := int(math.Ceil(window.Seconds()))
+	if n < 1 {
+		n = 1
+	}
+	return outcomeBuckets{buckets: make([]outcomeBucket, n)}
+}
+
+// bucket returns the bucket of second, clearing it when it still holds an older second
+func (o *outcomeBuckets) bucket(second int64) *outcomeBucket {
+	n := int64(len(o.buckets))
+	b := &o.buckets[(second%n+n)%n]
+	if b.second != second {
+		*b = outcomeBucket{second: second}
+	}
+	return b
+}
+
+func (o *outcomeBuckets) record(success bool, at time.Time) {
+	b := o.bucket(at.Unix())
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+}
+
+// rate returns the failure fraction of the outcomes within d before now, limited to the window
+func (o *outcomeBuckets) rate(d time.Duration, now time.Time) float64 {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds > int64(len(o.buckets)) {
+		seconds = int64(len(o.buckets))
+	}
+
+	var successes, failures uint64
+	current := now.Unix()
+	for _, b := range o.buckets {
+		if b.second > current-seconds && b.second <= current {
+			successes += b.successes
+			failures += b.failures
+		}
+	}
+
+	if successes+failures == 0 {
+		return 0
+	}
+	return float64(failures) / float64(successes+failures)
+}
+
+// RecentErrorRate returns the fraction of executions which failed within the last d,
+// regardless of the state. Looks back at most ErrorRateWindow and returns zero without executions
+func (c *circuitBreaker) RecentErrorRate(d time.Duration) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.outcomes.rate(d, c.strategy.Clock.Now())
+}
@@ -0,0 +1,41 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestRecentErrorRateOverFakeClockWindow(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 100, Clock: clock})
+
+	okFunc := func() (interface{}, error) {
+		return "success", nil
+	}
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	assert.Equal(t, cb.RecentErrorRate(time.Minute), 0.0)
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	cb.Execute(okFunc)
+
+	clock.Advance(time.Second * 30)
+	cb.Execute(errFunc)
+	cb.Execute(okFunc)
+	cb.Execute(okFunc)
+	cb.Execute(okFunc)
+
+	assert.Equal(t, cb.RecentErrorRate(time.Second*10), 0.25)
+	assert.Equal(t, cb.RecentErrorRate(time.Minute), 3.0/7.0)
+
+	// the first outcomes leave the window
+	clock.Advance(time.Second * 31)
+	assert.Equal(t, cb.RecentErrorRate(time.Minute), 0.25)
+	assert.Equal(t, cb.RecentErrorRate(time.Second*10), 0.0)
+	assert.Equal(t, cb.GetState(), Closed)
+}
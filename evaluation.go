@@ -0,0 +1,43 @@
+package go_circuit_breaker
+
+import "time"
+
+// TripEvaluation records the inputs and the outcome of a trip decision
+type TripEvaluation struct {
+	Time   time.Time
+	Counts Counts
+	// Threshold and Window are reported by failure counters implementing TripCriteria.
+	// During the PostRecoveryProbation Threshold is the ProbationThreshold
+	Threshold float64
+	Window    time.Duration
+	// Category is set when the failures of an error category exceeded its threshold
+	Category string
+	// ShouldTrip is the decision of the failure counter and the category thresholds
+	ShouldTrip bool
+	// Tripped is unset when the TransitionGuard vetoed the trip
+	Tripped bool
+}
+
+// LastTripEvaluation returns the latest trip decision, zero when there was none yet
+func (c *circuitBreaker) LastTripEvaluation() TripEvaluation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lastEvaluation
+}
+
+// evaluation captures a trip decision made now. Must be called with the lock held
+func (c *circuitBreaker) evaluation(shouldTrip, tripped bool) TripEvaluation {
+	evaluation := TripEvaluation{
+		Time:       c.strategy.Clock.Now(),
+		Counts:     c.counts,
+		ShouldTrip: shouldTrip,
+		Tripped:    tripped,
+	}
+	if c.onProbation() {
+		evaluation.Threshold = float64(c.strategy.ProbationThreshold)
+	} else if criteria, ok := c.counter.(TripCriteria); ok {
+		evaluation.Threshold, evaluation.Window = criteria.Criteria()
+	}
+	return evaluation
+}
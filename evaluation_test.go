@@ -0,0 +1,54 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestLastTripEvaluationCapturesCountsLeadingToTrip(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Clock: clock}, WithCounter(NewSlidingWindowCounter(2, time.Minute)))
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	assert.Equal(t, cb.LastTripEvaluation(), TripEvaluation{})
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	evaluation := cb.LastTripEvaluation()
+	assert.Equal(t, evaluation.ShouldTrip, false)
+	assert.Equal(t, evaluation.Counts.TotalFailures, uint64(2))
+
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.LastTripEvaluation(), TripEvaluation{
+		Time:       clock.Now(),
+		Counts:     Counts{Requests: 3, TotalFailures: 3, ConsecutiveFailures: 3},
+		Threshold:  2,
+		Window:     time.Minute,
+		ShouldTrip: true,
+		Tripped:    true,
+	})
+}
+
+func TestWhenTripIsVetoedEvaluationTellsSo(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, TransitionGuard: func(from, to State, counts Counts) bool {
+		return false
+	}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	evaluation := cb.LastTripEvaluation()
+	assert.Equal(t, evaluation.ShouldTrip, true)
+	assert.Equal(t, evaluation.Tripped, false)
+	assert.Equal(t, evaluation.Threshold, 1.0)
+	assert.Equal(t, cb.GetState(), Closed)
+}
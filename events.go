@@ -0,0 +1,117 @@
+package go_circuit_breaker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies what happened in a circuit breaker
+type EventType int
+
+const (
+	EventStateChange       EventType = 1
+	EventTrip              EventType = 2
+	EventProbe             EventType = 3
+	EventReject            EventType = 4
+	EventRecoveryExhausted EventType = 5
+)
+
+// Severity rates how notable an event is
+type Severity int
+
+const (
+	Info     Severity = 1
+	Warning  Severity = 2
+	Critical Severity = 3
+)
+
+const defaultEventBuffer = 64
+
+// Event describes a notable occurrence in a circuit breaker
+type Event struct {
+	Type     EventType
+	Severity Severity
+	Name     string
+	Time     time.Time
+	// From and To are set for state changes
+	From State
+	To   State
+	// Manual is set for state changes forced by an operator through methods like ForceOpen or Reset
+	Manual bool
+	// Err holds the error of failed probes and trips
+	Err error
+}
+
+// Events returns the stream of events of the circuit breaker.
+// Events are dropped instead of blocking when the buffer is full
+func (c *circuitBreaker) Events() <-chan Event {
+	return c.events
+}
+
+// DroppedEvents returns the number of events dropped because of a full buffer
+func (c *circuitBreaker) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&c.droppedEvents)
+}
+
+// emit delivers an event without blocking
+func (c *circuitBreaker) emit(event Event) {
+	event.Name = c.name
+	event.Time = c.strategy.Clock.Now()
+
+	select {
+	case c.events <- event:
+	default:
+		atomic.AddUint64(&c.droppedEvents, 1)
+	}
+}
+
+// emitStateChange emits a state change limited to MaxEventRate. Must be called with the lock held
+func (c *circuitBreaker) emitStateChange(event Event) {
+	c.stats.StateChanges++
+	if c.strategy.MaxEventRate <= 0 {
+		c.emit(event)
+		return
+	}
+
+	if c.pendingState != nil {
+		c.pendingState.To = event.To
+		c.pendingState.Severity = event.Severity
+		c.pendingState.Manual = event.Manual
+		return
+	}
+
+	now := c.strategy.Clock.Now()
+	interval := time.Duration(float64(time.Second) / c.strategy.MaxEventRate)
+	if c.lastStateEvent.IsZero() || now.Sub(c.lastStateEvent) >= interval {
+		c.lastStateEvent = now
+		c.emit(event)
+		return
+	}
+
+	c.pendingState = &event
+	c.strategy.Clock.AfterFunc(c.lastStateEvent.Add(interval).Sub(now), c.flushStateChange)
+}
+
+// flushStateChange emits the coalesced state change held back by MaxEventRate
+func (c *circuitBreaker) flushStateChange() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	event := c.pendingState
+	c.pendingState = nil
+	c.lastStateEvent = c.strategy.Clock.Now()
+	if event != nil && event.From != event.To {
+		c.emit(*event)
+	}
+}
+
+// stateSeverity rates a change to the given state
+func stateSeverity(to State) Severity {
+	switch to {
+	case Open:
+		return Critical
+	case HalfOpen:
+		return Warning
+	}
+	return Info
+}
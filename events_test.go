@@ -0,0 +1,168 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// drain returns all events buffered so far
+func drain(cb CircuitBreaker) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-cb.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestEventsOfTripAndRecovery(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	var failing int32 = 1
+	testFunc := func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+	cb.Execute(testFunc)
+
+	// first retry fails
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+
+	// second retry succeeds
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	atomic.StoreInt32(&failing, 0)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	events := drain(cb)
+	assert.Equal(t, eventTypes(events), []EventType{
+		EventTrip, EventStateChange, EventReject, EventProbe, EventProbe, EventStateChange,
+	})
+	assert.Equal(t, events[1].From, Closed)
+	assert.Equal(t, events[1].To, HalfOpen)
+	assert.Equal(t, events[3].Severity, Warning)
+	assert.Equal(t, events[4].Severity, Info)
+	assert.Equal(t, events[5].From, HalfOpen)
+	assert.Equal(t, events[5].To, Closed)
+	assert.Equal(t, events[5].Name, "test")
+}
+
+func TestEventsOfExhaustedRecovery(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, RetryMax: 1, Clock: clock, Logger: &recordingLogger{}})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return cb.GetState() == Open })
+	cb.Execute(errFunc)
+
+	events := drain(cb)
+	assert.Equal(t, eventTypes(events), []EventType{
+		EventTrip, EventStateChange, EventProbe, EventProbe, EventRecoveryExhausted, EventStateChange, EventReject,
+	})
+	assert.Equal(t, events[4].Severity, Critical)
+	assert.Equal(t, events[5].To, Open)
+}
+
+func TestWhenEventBufferIsFullEventsAreDropped(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, EventBuffer: 2})
+	cb.(*circuitBreaker).state = HalfOpen
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	for i := 0; i < 5; i++ {
+		cb.Execute(happyFunc)
+	}
+
+	assert.Equal(t, len(drain(cb)), 2)
+	assert.Equal(t, cb.DroppedEvents(), uint64(3))
+}
+
+func TestStateChangesTellManualFromAutomaticTransitions(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	cb.ForceOpen("alice")
+	cb.ForceHalfOpen("test")
+	cb.Reset("test")
+
+	var changes []Event
+	for _, event := range drain(cb) {
+		if event.Type == EventStateChange {
+			changes = append(changes, event)
+		}
+	}
+
+	assert.Equal(t, len(changes), 4)
+	assert.Equal(t, changes[0].To, HalfOpen)
+	assert.Equal(t, changes[0].Manual, false)
+	assert.Equal(t, changes[1].To, Open)
+	assert.Equal(t, changes[1].Manual, true)
+	assert.Equal(t, changes[2].To, HalfOpen)
+	assert.Equal(t, changes[2].Manual, true)
+	assert.Equal(t, changes[3].To, Closed)
+	assert.Equal(t, changes[3].Manual, true)
+}
+
+func TestWhenFlappingStateChangeEventsAreRateLimited(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{MaxEventRate: 1, Clock: clock})
+
+	cb.ForceOpen("test")
+	cb.Reset("test")
+	cb.ForceHalfOpen("test")
+	cb.Reset("test")
+	cb.ForceHalfOpen("test")
+	assert.Equal(t, len(drain(cb)), 1)
+
+	// intermediate changes are coalesced once the interval passed
+	clock.Advance(time.Second)
+	events := drain(cb)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].From, Open)
+	assert.Equal(t, events[0].To, HalfOpen)
+	assert.Equal(t, cb.Stats().StateChanges, uint64(5))
+
+	clock.Advance(time.Second)
+	cb.Reset("test")
+	events = drain(cb)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].To, Closed)
+}
@@ -0,0 +1,188 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errRecordedFailure is counted for failures reported through Record
+var errRecordedFailure = errors.New("circuit breaker recorded failure")
+
+// permit tells how the outcome of an admitted operation is recorded
+type permit struct {
+	// probe is set for operations admitted as probe
+	probe bool
+	// trips is the trip count at admission. Outcomes of operations admitted before a trip are ignored
+	trips uint64
+	// stale is set when the operation is known to be admitted before a trip
+	stale bool
+}
+
+// gatePermits tracks the permits of Allow until Record reports their outcomes. As Record does not tell
+// which operation finished, outcomes are matched to the outstanding permits by count: first to operations
+// allowed before the last trip, then to probes
+type gatePermits struct {
+	probes int
+	// closed counts the operations allowed in Closed before trips, stale the ones allowed before earlier trips
+	closed int
+	stale  int
+	trips  uint64
+}
+
+// age moves the permits of operations allowed before the current trip count to stale
+func (g *gatePermits) age(trips uint64) {
+	if g.trips != trips {
+		g.stale += g.closed
+		g.closed, g.trips = 0, trips
+	}
+}
+
+// Allow reports whether the caller may run its operation and counts it like an execution.
+// Every allowed operation must report its outcome through Record. Once tripped, allowed operations
+// in HalfOpen probe the dependency unless a probe function is configured. Outcomes of operations allowed
+// before a trip are ignored
+func (c *circuitBreaker) Allow() bool {
+	p, err := c.admitted()
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gatePermits.age(c.trips)
+	if p.probe {
+		c.gatePermits.probes++
+	} else {
+		c.gatePermits.closed++
+	}
+	return true
+}
+
+// admitted works like Allow and returns the permit of the admitted operation or the error a rejected
+// execution gets
+func (c *circuitBreaker) admitted() (permit, error) {
+	state, _, callerProbe, shed := c.admission(false)
+	if shed {
+		return permit{}, ErrLoadShed
+	}
+	if state != Closed && !callerProbe {
+		return permit{}, c.rejection(state)
+	}
+
+	c.admit(state)
+	return permit{probe: callerProbe, trips: c.tripCount()}, nil
+}
+
+// Record reports the outcome of an operation allowed by Allow
+func (c *circuitBreaker) Record(success bool) {
+	var failure error
+	if !success {
+		failure = errRecordedFailure
+	}
+	c.record(c.gatePermit(), failure)
+}
+
+// gatePermit takes the outstanding permit of Allow the next outcome is matched to
+func (c *circuitBreaker) gatePermit() permit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := &c.gatePermits
+	g.age(c.trips)
+	switch {
+	case g.stale > 0:
+		g.stale--
+		return permit{stale: true}
+	case g.probes > 0:
+		g.probes--
+		return permit{probe: true}
+	case g.closed > 0:
+		g.closed--
+	}
+	return permit{trips: c.trips}
+}
+
+// record reports the failure of an operation admitted with p, nil when it succeeded
+func (c *circuitBreaker) record(p permit, failure error) {
+	if p.probe {
+		c.releaseProbeSlot()
+		c.handleProbe(failure, false, 1)
+		return
+	}
+	if p.stale || c.tripCount() != p.trips {
+		// finished after a trip it had no part in
+		return
+	}
+
+	if failure != nil {
+		c.handleError(nil, failure)
+	} else {
+		c.handleSuccess()
+	}
+}
+
+// allow admits an operation through cb like Allow and returns the error a rejected execution gets,
+// annotated from ctx like by ExecuteWithContext. The operation is never replayed, see ExecuteWithContext
+func allow(ctx context.Context, cb CircuitBreaker) (permit, error) {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		if cb.Allow() {
+			return permit{}, nil
+		}
+		return permit{}, rejectionOf(cb)
+	}
+
+	p, err := c.admitted()
+	if openErr, ok := err.(*OpenError); ok {
+		c.annotate(ctx, openErr)
+	}
+	return p, err
+}
+
+// rejectionOf returns an *OpenError for a rejection by cb when the original one is not at hand
+func rejectionOf(cb CircuitBreaker) *OpenError {
+	state := cb.GetState()
+	return &OpenError{Name: cb.GetName(), State: state, err: fmt.Errorf("%v circuit breaker %v", cb.GetName(), state)}
+}
+
+// withdraw takes back the admission of an operation which did not run after all, as if it was never admitted
+func withdraw(cb CircuitBreaker, p permit) {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		return
+	}
+	if p.probe {
+		c.releaseProbeSlot()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Requests--
+	c.counts.Requests--
+	if p.probe {
+		// the probe of this retry interval is still due
+		c.lastProbe = time.Time{}
+	}
+}
+
+// recordOutcome reports the outcome of an operation admitted by allow with p, classified by the strategy of cb
+func recordOutcome(cb CircuitBreaker, p permit, res interface{}, err error) {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		cb.Record(err == nil)
+		return
+	}
+
+	failure, _, counts := c.classify(res, err)
+	if !counts {
+		if p.probe {
+			c.releaseProbeSlot()
+		}
+		return
+	}
+	c.record(p, failure)
+}
@@ -0,0 +1,67 @@
+package go_circuit_breaker
+
+import (
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestGateThenReportTripsAndRecovers(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	report := func(success bool) bool {
+		if !cb.Allow() {
+			return false
+		}
+		cb.Record(success)
+		return true
+	}
+
+	assert.Equal(t, report(true), true)
+	assert.Equal(t, report(false), true)
+	assert.Equal(t, report(false), true)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// allowed operations probe once per retry interval
+	assert.Equal(t, report(false), true)
+	assert.Equal(t, cb.Allow(), false)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, report(true), true)
+	assert.Equal(t, cb.GetState(), Closed)
+
+	stats := cb.Stats()
+	assert.Equal(t, stats.Requests, uint64(5))
+	assert.Equal(t, stats.Rejections, uint64(1))
+	assert.Equal(t, stats.Failures, uint64(3))
+}
+
+func TestWhenOpenAllowRejects(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+	cb.ForceOpen("test")
+
+	assert.Equal(t, cb.Allow(), false)
+	assert.Equal(t, cb.Stats().Rejections, uint64(1))
+}
+
+func TestOutcomeOfOperationAllowedBeforeTripDoesNotProbe(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1})
+
+	// still running while the breaker trips
+	assert.Equal(t, cb.Allow(), true)
+	for i := 0; i < 2; i++ {
+		cb.Allow()
+		cb.Record(false)
+	}
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	cb.Record(true)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// the next allowed operation is the probe
+	assert.Equal(t, cb.Allow(), true)
+	cb.Record(true)
+	assert.Equal(t, cb.GetState(), Closed)
+}
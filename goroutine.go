@@ -0,0 +1,73 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// PanicError is counted as failure for functions run by Go which panicked
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("circuit breaker background function panicked: %v", e.Value)
+}
+
+// Go runs f through the circuit breaker in a new goroutine. A panic of f is recovered and counted
+// as failure with a *PanicError instead of crashing the process. Rejected functions are not run,
+// and neither is f run again for recovery when it trips the circuit breaker
+func (c *circuitBreaker) Go(f func() error) {
+	c.spawn(func() {
+		_, err := c.execute(c.timed(c.strategy.Timeout, func() (res interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r}
+				}
+			}()
+			return nil, f()
+		}), false)
+		if openErr, ok := err.(*OpenError); ok {
+			c.fallback(openErr.err)
+		}
+	})
+}
+
+// errStopped is returned by probes abandoned because the circuit breaker was stopped
+var errStopped = errors.New("circuit breaker stopped")
+
+// Stop ends the background recovery and abandons running probes. A stopped circuit breaker keeps working,
+// but only recovers through admitted executions like one started in HalfOpen. Functions run by Go, timed out
+// executions and asynchronous fallbacks keep running until they return
+func (c *circuitBreaker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// stopped reports whether Stop was called
+func (c *circuitBreaker) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// spawn runs f in a goroutine counted by ActiveGoroutines
+func (c *circuitBreaker) spawn(f func()) {
+	atomic.AddInt64(&c.goroutines, 1)
+	go func() {
+		defer atomic.AddInt64(&c.goroutines, -1)
+		f()
+	}()
+}
+
+// ActiveGoroutines returns the number of background goroutines still running, such as recoveries,
+// timed out executions and functions passed to Go. Users and CI can assert it drops to zero after Stop
+// to rule out leaks
+func (c *circuitBreaker) ActiveGoroutines() int {
+	return int(atomic.LoadInt64(&c.goroutines))
+}
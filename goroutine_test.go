@@ -0,0 +1,104 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWhenBackgroundFunctionPanicsFailureIsRecorded(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5})
+
+	cb.Go(func() error {
+		panic("boom")
+	})
+	waitFor(t, func() bool { return cb.Stats().Failures == 1 })
+
+	cb.Go(func() error {
+		return nil
+	})
+	waitFor(t, func() bool { return cb.Stats().Successes == 1 })
+	assert.Equal(t, cb.Counts().TotalFailures, uint64(1))
+}
+
+func TestPanicOfBackgroundFunctionTripsBreaker(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	for i := 0; i < 2; i++ {
+		cb.Go(func() error {
+			panic("boom")
+		})
+	}
+	waitFor(t, func() bool { return cb.GetState() == HalfOpen })
+
+	events := drain(cb)
+	assert.Equal(t, events[0].Type, EventTrip)
+	assert.Equal(t, events[0].Err, error(&PanicError{Value: "boom"}))
+}
+
+func TestWhenBackgroundFunctionTripsBreakerItIsNotRunAgain(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	var runs int32
+	for i := 0; i < 2; i++ {
+		cb.Go(func() error {
+			atomic.AddInt32(&runs, 1)
+			return errors.New("i like to fail")
+		})
+	}
+	waitFor(t, func() bool { return cb.GetState() == HalfOpen })
+	waitFor(t, func() bool { return cb.ActiveGoroutines() == 0 })
+
+	clock.Advance(time.Second * 10)
+	assert.Equal(t, clock.Pending(), 0)
+	assert.Equal(t, atomic.LoadInt32(&runs), int32(2))
+}
+
+func TestWhenStoppedNoGoroutinesLeak(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		Timeout:       time.Minute,
+		AsyncFallback: true,
+		Clock:         clock,
+		Fallback: func(err error) (interface{}, error) {
+			<-release
+			return nil, nil
+		},
+		ProbeWithContext: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	started := make(chan struct{})
+	cb.Go(func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	// recovery probes with a hanging probe while the fallback serves rejections.
+	// Pending are the recovery and the timeouts of the three executions
+	waitFor(t, func() bool { return clock.Pending() == 4 })
+	clock.Advance(time.Second)
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.ActiveGoroutines() > 0, true)
+
+	cb.Stop()
+	close(release)
+	waitFor(t, func() bool { return cb.ActiveGoroutines() == 0 })
+}
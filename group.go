@@ -0,0 +1,25 @@
+package go_circuit_breaker
+
+// Group executes functions through a separate circuit breaker per name
+type Group struct {
+	registry *Registry
+}
+
+// NewGroup returns new group creating circuit breakers with the given strategy
+func NewGroup(strategy *Strategy) *Group {
+	return &Group{registry: NewRegistry(strategy)}
+}
+
+// Execute executes a function wrapped in the circuit breaker for name
+func (g *Group) Execute(name string, f func() (interface{}, error)) (interface{}, error) {
+	return g.registry.Get(name).Execute(f)
+}
+
+// States returns the state of every circuit breaker in the group by name
+func (g *Group) States() map[string]State {
+	states := make(map[string]State)
+	for _, cb := range g.registry.snapshot() {
+		states[cb.GetName()] = cb.GetState()
+	}
+	return states
+}
@@ -0,0 +1,46 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+)
+
+func TestWhenOneNameTripsOtherNamesRemainClosed(t *testing.T) {
+	g := NewGroup(&Strategy{Threshold: 1})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	g.Execute("flaky", errFunc)
+	g.Execute("flaky", errFunc)
+	_, err := g.Execute("flaky", errFunc)
+	assert.Equal(t, err, errors.New("circuit half open. trying to recover"))
+
+	res, err := g.Execute("stable", happyFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+}
+
+func TestGroupStatesReportsEveryName(t *testing.T) {
+	g := NewGroup(&Strategy{Threshold: 1})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	g.Execute("flaky", errFunc)
+	g.Execute("flaky", errFunc)
+	g.Execute("stable", happyFunc)
+
+	assert.Equal(t, g.States(), map[string]State{"flaky": HalfOpen, "stable": Closed})
+}
@@ -0,0 +1,23 @@
+package go_circuit_breaker
+
+import (
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// GRPCHealthUpdater returns an OnStateChange callback reporting service to the gRPC health server.
+// The service is SERVING while the circuit breaker is closed or half open and NOT_SERVING while open.
+// initial is the state the circuit breaker starts in, e.g. the one passed to WithInitialState. States restored
+// from a store are reported as they change the state
+func GRPCHealthUpdater(server *health.Server, service string, initial State) func(name string, from, to State) {
+	update := func(name string, from, to State) {
+		status := healthpb.HealthCheckResponse_SERVING
+		if to == Open {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		server.SetServingStatus(service, status)
+	}
+	update(service, initial, initial)
+
+	return update
+}
@@ -0,0 +1,75 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"testing"
+	"time"
+)
+
+func servingStatus(t *testing.T, server *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.Equal(t, err, nil)
+	return resp.GetStatus()
+}
+
+func TestGRPCHealthFollowsBreakerState(t *testing.T) {
+	server := health.NewServer()
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("payments", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		RetryMax:      1,
+		Clock:         clock,
+		Logger:        &recordingLogger{},
+		OnStateChange: GRPCHealthUpdater(server, "payments", Closed),
+	})
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_SERVING)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	// half open still serves
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_SERVING)
+
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return cb.GetState() == Open })
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_NOT_SERVING)
+
+	cb.Reset("test")
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestGRPCHealthStartsFromInitialState(t *testing.T) {
+	server := health.NewServer()
+	NewCircuitBreaker("payments", &Strategy{
+		ManualRecoveryOnly: true,
+		Logger:             &recordingLogger{},
+		OnStateChange:      GRPCHealthUpdater(server, "payments", Open),
+	}, WithInitialState(Open))
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestGRPCHealthFollowsRestoredState(t *testing.T) {
+	store := NewMemoryStore()
+	previous := NewWithStore("payments", &Strategy{ManualRecoveryOnly: true, Logger: &recordingLogger{}}, store)
+	previous.ForceOpen("test")
+
+	server := health.NewServer()
+	NewWithStore("payments", &Strategy{
+		ManualRecoveryOnly: true,
+		Logger:             &recordingLogger{},
+		OnStateChange:      GRPCHealthUpdater(server, "payments", Closed),
+	}, store)
+	assert.Equal(t, servingStatus(t, server, "payments"), healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -0,0 +1,38 @@
+package go_circuit_breaker
+
+import "time"
+
+// StateChange is a state change retained in the transition history
+type StateChange struct {
+	Time   time.Time
+	From   State
+	To     State
+	Reason string
+	Manual bool
+}
+
+// TransitionHistory returns the latest state changes, oldest first, up to HistorySize
+func (c *circuitBreaker) TransitionHistory() []StateChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]StateChange(nil), c.history...)
+}
+
+// remember retains the state change, dropping the oldest beyond HistorySize. Must be called with the lock held
+func (c *circuitBreaker) remember(from, to State, why cause) {
+	if c.strategy.HistorySize <= 0 {
+		return
+	}
+
+	c.history = append(c.history, StateChange{
+		Time:   c.strategy.Clock.Now(),
+		From:   from,
+		To:     to,
+		Reason: why.reason,
+		Manual: why.source == AuditManual,
+	})
+	if len(c.history) > c.strategy.HistorySize {
+		c.history = c.history[len(c.history)-c.strategy.HistorySize:]
+	}
+}
@@ -0,0 +1,63 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransitionHistoryOfFullCycle(t *testing.T) {
+	clock := newFakeClock()
+	var healthy int32
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		RetryMax:      1,
+		HistorySize:   3,
+		Clock:         clock,
+		Logger:        &recordingLogger{},
+		ProbeFunc: func() error {
+			if atomic.LoadInt32(&healthy) == 1 {
+				return nil
+			}
+			return errors.New("still down")
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	assert.Equal(t, len(cb.TransitionHistory()), 0)
+
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	// two failed probes exhaust recovery
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+		clock.Advance(time.Second)
+	}
+	waitFor(t, func() bool { return cb.GetState() == Open })
+
+	atomic.StoreInt32(&healthy, 1)
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, cb.TransitionHistory(), []StateChange{
+		{Time: start, From: Closed, To: HalfOpen, Reason: "failure threshold reached"},
+		{Time: start.Add(time.Second * 2), From: HalfOpen, To: Open, Reason: "recovery exhausted"},
+		{Time: start.Add(time.Second * 3), From: Open, To: Closed, Reason: "recovered"},
+	})
+
+	// the oldest state change is dropped beyond the history size
+	cb.ForceOpen("alice")
+	history := cb.TransitionHistory()
+	assert.Equal(t, len(history), 3)
+	assert.Equal(t, history[0].To, Open)
+	assert.Equal(t, history[2], StateChange{Time: start.Add(time.Second * 3), From: Closed, To: Open, Reason: "forced open", Manual: true})
+}
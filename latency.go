@@ -0,0 +1,92 @@
+package go_circuit_breaker
+
+import (
+	"sort"
+	"time"
+)
+
+const latencySamples = 100
+
+var defaultLatencyBuckets = []time.Duration{
+	time.Millisecond * 10,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 250,
+	time.Millisecond * 500,
+	time.Second,
+	time.Second * 5,
+}
+
+// LatencyBucket counts executions which took longer than the previous bucket and at most UpperBound
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// latencyTracker keeps the latencies of the most recent executions and a histogram of all of them
+type latencyTracker struct {
+	samples []time.Duration
+	next    int
+
+	buckets []LatencyBucket
+	count   uint64
+	sum     time.Duration
+}
+
+// newLatencyTracker returns new tracker with a histogram of the given bucket bounds
+func newLatencyTracker(bounds []time.Duration) latencyTracker {
+	sorted := append([]time.Duration(nil), bounds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	buckets := make([]LatencyBucket, len(sorted))
+	for i, bound := range sorted {
+		buckets[i].UpperBound = bound
+	}
+	return latencyTracker{buckets: buckets}
+}
+
+func (l *latencyTracker) record(d time.Duration) {
+	l.count++
+	l.sum += d
+	for i := range l.buckets {
+		if d <= l.buckets[i].UpperBound {
+			l.buckets[i].Count++
+			break
+		}
+	}
+
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+// percentile returns the latency below which the fraction p of the samples fall
+func (l *latencyTracker) percentile(p float64) time.Duration {
+	if len(l.samples) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), l.samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// histogram returns a copy of the latency buckets
+func (l *latencyTracker) histogram() []LatencyBucket {
+	return append([]LatencyBucket(nil), l.buckets...)
+}
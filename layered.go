@@ -0,0 +1,47 @@
+package go_circuit_breaker
+
+import "context"
+
+// Layered guards one dependency with several circuit breakers of different strategies,
+// e.g. a fast one tripping on short spikes and a slow one tripping on sustained failure rates.
+// An execution is rejected as soon as one of them does not admit it
+type Layered struct {
+	breakers []CircuitBreaker
+}
+
+// NewLayered returns new composite of the given circuit breakers
+func NewLayered(breakers ...CircuitBreaker) *Layered {
+	return &Layered{breakers: breakers}
+}
+
+// Execute executes a function once and records its outcome in every circuit breaker.
+// Returns *OpenError of the first circuit breaker rejecting it, taking back the admissions of the ones
+// before it. The function is never replayed, see ExecuteWithContext
+func (l *Layered) Execute(f func() (interface{}, error)) (interface{}, error) {
+	permits := make([]permit, len(l.breakers))
+	for i, cb := range l.breakers {
+		p, err := allow(context.Background(), cb)
+		if err != nil {
+			for j := 0; j < i; j++ {
+				withdraw(l.breakers[j], permits[j])
+			}
+			return nil, err
+		}
+		permits[i] = p
+	}
+
+	res, err := f()
+	for i, cb := range l.breakers {
+		recordOutcome(cb, permits[i], res, err)
+	}
+	return res, err
+}
+
+// States returns the state of every circuit breaker by name
+func (l *Layered) States() map[string]State {
+	states := make(map[string]State, len(l.breakers))
+	for _, cb := range l.breakers {
+		states[cb.GetName()] = cb.GetState()
+	}
+	return states
+}
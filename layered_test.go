@@ -0,0 +1,118 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestWhenFastBreakerTripsLayeredRejects(t *testing.T) {
+	clock := newFakeClock()
+	fast := NewCircuitBreaker("fast", &Strategy{Threshold: 1, Clock: clock})
+	slow := NewCircuitBreaker("slow", &Strategy{Clock: clock}, WithCounter(NewRateCounter(0.5, 10, time.Minute)))
+	l := NewLayered(fast, slow)
+
+	executions := 0
+	errFunc := func() (interface{}, error) {
+		executions++
+		return nil, errors.New("i like to fail")
+	}
+
+	// the third execution probes the tripped fast breaker
+	l.Execute(errFunc)
+	l.Execute(errFunc)
+	l.Execute(errFunc)
+	_, err := l.Execute(errFunc)
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.Name, "fast")
+	assert.Equal(t, openErr.State, HalfOpen)
+	assert.Equal(t, err.Error(), "circuit half open. trying to recover")
+	assert.Equal(t, executions, 3)
+	assert.Equal(t, l.States(), map[string]State{"fast": HalfOpen, "slow": Closed})
+}
+
+func TestWhenSlowBreakerTripsLayeredRejects(t *testing.T) {
+	clock := newFakeClock()
+	fast := NewCircuitBreaker("fast", &Strategy{Threshold: 2, Clock: clock})
+	slow := NewCircuitBreaker("slow", &Strategy{Clock: clock}, WithCounter(NewRateCounter(0.5, 4, time.Minute)))
+	l := NewLayered(fast, slow)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	// alternating failures never trip the fast breaker but exceed the slow error rate
+	l.Execute(errFunc)
+	l.Execute(happyFunc)
+	l.Execute(errFunc)
+	l.Execute(happyFunc)
+	l.Execute(errFunc)
+	assert.Equal(t, l.States(), map[string]State{"fast": Closed, "slow": HalfOpen})
+
+	l.Execute(errFunc)
+	_, err := l.Execute(happyFunc)
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.Name, "slow")
+	assert.Equal(t, l.States(), map[string]State{"fast": Closed, "slow": HalfOpen})
+}
+
+func TestLayeredRecoversOnceDependencyRecovered(t *testing.T) {
+	clock := newFakeClock()
+	fast := NewCircuitBreaker("fast", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+	slow := NewCircuitBreaker("slow", &Strategy{Clock: clock}, WithCounter(NewRateCounter(0.5, 10, time.Minute)))
+	l := NewLayered(fast, slow)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	l.Execute(errFunc)
+	l.Execute(errFunc)
+	l.Execute(errFunc)
+	assert.Equal(t, l.States(), map[string]State{"fast": HalfOpen, "slow": Closed})
+
+	// the dependency recovered by the next probe
+	clock.Advance(time.Second)
+	res, err := l.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, l.States(), map[string]State{"fast": Closed, "slow": Closed})
+}
+
+func TestWhenLaterBreakerRejectsEarlierAdmissionsAreTakenBack(t *testing.T) {
+	reg := NewRegistry(&Strategy{Threshold: 1, MaxConcurrentProbes: 1})
+	first := reg.Get("first")
+	for i := 0; i < 2; i++ {
+		first.Allow()
+		first.Record(false)
+	}
+	assert.Equal(t, first.GetState(), HalfOpen)
+	requests := first.Stats().Requests
+
+	second := NewCircuitBreaker("second", &Strategy{Logger: &recordingLogger{}})
+	second.ForceOpen("test")
+
+	_, err := NewLayered(first, second).Execute(func() (interface{}, error) {
+		t.Fatal("executed while rejected")
+		return nil, nil
+	})
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.Name, "second")
+
+	// the probe of the first breaker is still due and holds no slot
+	assert.Equal(t, first.Stats().Requests, requests)
+	assert.Equal(t, reg.ActiveProbes(), 0)
+	assert.Equal(t, first.Allow(), true)
+}
@@ -0,0 +1,165 @@
+package go_circuit_breaker
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// encodedBreaker is the document written by MarshalJSON
+type encodedBreaker struct {
+	Name      string          `json:"name"`
+	State     State           `json:"state"`
+	Pinned    bool            `json:"pinned,omitempty"`
+	OpenedAt  *time.Time      `json:"opened_at,omitempty"`
+	ChangedAt *time.Time      `json:"changed_at,omitempty"`
+	Counts    Counts          `json:"counts"`
+	Strategy  encodedStrategy `json:"strategy"`
+}
+
+// encodedStrategy holds the plain values of a strategy. Functions and interfaces cannot be encoded
+type encodedStrategy struct {
+	Threshold                  int             `json:"threshold"`
+	RetryInterval              int             `json:"retry_interval"`
+	RetryMax                   int             `json:"retry_max"`
+	SuccessThreshold           int             `json:"success_threshold"`
+	MinSuccessLatency          time.Duration   `json:"min_success_latency"`
+	ManualRecoveryOnly         bool            `json:"manual_recovery_only"`
+	SoftOpenSampleRate         float64         `json:"soft_open_sample_rate"`
+	HalfOpenFailureGrace       int             `json:"half_open_failure_grace"`
+	PostRecoveryProbation      time.Duration   `json:"post_recovery_probation"`
+	ProbationThreshold         int             `json:"probation_threshold"`
+	Timeout                    time.Duration   `json:"timeout"`
+	AllowDoubleExecute         bool            `json:"allow_double_execute"`
+	ConnectErrorsCount         bool            `json:"connect_errors_count"`
+	CategoryThresholds         map[string]int  `json:"category_thresholds,omitempty"`
+	DedupWindow                time.Duration   `json:"dedup_window"`
+	SlowLogThreshold           time.Duration   `json:"slow_log_threshold"`
+	AlertAfter                 time.Duration   `json:"alert_after"`
+	LoadShedTarget             time.Duration   `json:"load_shed_target"`
+	LatencyBuckets             []time.Duration `json:"latency_buckets"`
+	ErrorRateWindow            time.Duration   `json:"error_rate_window"`
+	ProbeTimeout               time.Duration   `json:"probe_timeout"`
+	AsyncFallback              bool            `json:"async_fallback"`
+	HalfOpenEntryErrorReset    int             `json:"half_open_entry_error_reset"`
+	CountProbeFailuresInWindow bool            `json:"count_probe_failures_in_window"`
+	HalfOpenShareProbeResult   bool            `json:"half_open_share_probe_result"`
+	HalfOpenShareTimeout       time.Duration   `json:"half_open_share_timeout"`
+	HistorySize                int             `json:"history_size"`
+	EventBuffer                int             `json:"event_buffer"`
+	MaxEventRate               float64         `json:"max_event_rate"`
+	IdleTimeout                time.Duration   `json:"idle_timeout"`
+	MaxConcurrentProbes        int             `json:"max_concurrent_probes"`
+	MaxKeys                    int             `json:"max_keys"`
+}
+
+func encodeStrategy(s *Strategy) encodedStrategy {
+	return encodedStrategy{
+		Threshold:                  s.Threshold,
+		RetryInterval:              s.RetryInterval,
+		RetryMax:                   s.RetryMax,
+		SuccessThreshold:           s.SuccessThreshold,
+		MinSuccessLatency:          s.MinSuccessLatency,
+		ManualRecoveryOnly:         s.ManualRecoveryOnly,
+		SoftOpenSampleRate:         s.SoftOpenSampleRate,
+		HalfOpenFailureGrace:       s.HalfOpenFailureGrace,
+		PostRecoveryProbation:      s.PostRecoveryProbation,
+		ProbationThreshold:         s.ProbationThreshold,
+		Timeout:                    s.Timeout,
+		AllowDoubleExecute:         s.AllowDoubleExecute,
+		ConnectErrorsCount:         s.ConnectErrorsCount,
+		CategoryThresholds:         s.CategoryThresholds,
+		DedupWindow:                s.DedupWindow,
+		SlowLogThreshold:           s.SlowLogThreshold,
+		AlertAfter:                 s.AlertAfter,
+		LoadShedTarget:             s.LoadShedTarget,
+		LatencyBuckets:             s.LatencyBuckets,
+		ErrorRateWindow:            s.ErrorRateWindow,
+		ProbeTimeout:               s.ProbeTimeout,
+		AsyncFallback:              s.AsyncFallback,
+		HalfOpenEntryErrorReset:    s.HalfOpenEntryErrorReset,
+		CountProbeFailuresInWindow: s.CountProbeFailuresInWindow,
+		HalfOpenShareProbeResult:   s.HalfOpenShareProbeResult,
+		HalfOpenShareTimeout:       s.HalfOpenShareTimeout,
+		HistorySize:                s.HistorySize,
+		EventBuffer:                s.EventBuffer,
+		MaxEventRate:               s.MaxEventRate,
+		IdleTimeout:                s.IdleTimeout,
+		MaxConcurrentProbes:        s.MaxConcurrentProbes,
+		MaxKeys:                    s.MaxKeys,
+	}
+}
+
+// apply sets the plain values of s, keeping its functions and interfaces
+func (e encodedStrategy) apply(s *Strategy) {
+	s.Threshold = e.Threshold
+	s.RetryInterval = e.RetryInterval
+	s.RetryMax = e.RetryMax
+	s.SuccessThreshold = e.SuccessThreshold
+	s.MinSuccessLatency = e.MinSuccessLatency
+	s.ManualRecoveryOnly = e.ManualRecoveryOnly
+	s.SoftOpenSampleRate = e.SoftOpenSampleRate
+	s.HalfOpenFailureGrace = e.HalfOpenFailureGrace
+	s.PostRecoveryProbation = e.PostRecoveryProbation
+	s.ProbationThreshold = e.ProbationThreshold
+	s.Timeout = e.Timeout
+	s.AllowDoubleExecute = e.AllowDoubleExecute
+	s.ConnectErrorsCount = e.ConnectErrorsCount
+	s.CategoryThresholds = e.CategoryThresholds
+	s.DedupWindow = e.DedupWindow
+	s.SlowLogThreshold = e.SlowLogThreshold
+	s.AlertAfter = e.AlertAfter
+	s.LoadShedTarget = e.LoadShedTarget
+	s.LatencyBuckets = e.LatencyBuckets
+	s.ErrorRateWindow = e.ErrorRateWindow
+	s.ProbeTimeout = e.ProbeTimeout
+	s.AsyncFallback = e.AsyncFallback
+	s.HalfOpenEntryErrorReset = e.HalfOpenEntryErrorReset
+	s.CountProbeFailuresInWindow = e.CountProbeFailuresInWindow
+	s.HalfOpenShareProbeResult = e.HalfOpenShareProbeResult
+	s.HalfOpenShareTimeout = e.HalfOpenShareTimeout
+	s.HistorySize = e.HistorySize
+	s.EventBuffer = e.EventBuffer
+	s.MaxEventRate = e.MaxEventRate
+	s.IdleTimeout = e.IdleTimeout
+	s.MaxConcurrentProbes = e.MaxConcurrentProbes
+	s.MaxKeys = e.MaxKeys
+}
+
+// MarshalJSON encodes the name, state, counts and the plain values of the effective strategy as one document
+func (c *circuitBreaker) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	doc := encodedBreaker{
+		Name:      c.name,
+		State:     c.state,
+		Pinned:    c.pinned,
+		OpenedAt:  optionalTime(c.openedAt),
+		ChangedAt: optionalTime(c.changedAt),
+		Counts:    c.counts,
+		Strategy:  encodeStrategy(c.strategy),
+	}
+	c.mu.Unlock()
+
+	return json.Marshal(doc)
+}
+
+// NewCircuitBreakerFromJSON rebuilds a circuit breaker from a document written by MarshalJSON.
+// The functions and interfaces which cannot be encoded, like Fallback or Clock, are taken from strategy
+// if not nil. Its plain values are replaced by the encoded ones without modifying it
+func NewCircuitBreakerFromJSON(data []byte, strategy *Strategy, opts ...Option) (CircuitBreaker, error) {
+	var doc encodedBreaker
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	s := &Strategy{}
+	if strategy != nil {
+		*s = *strategy
+	}
+	doc.Strategy.apply(s)
+
+	c := NewCircuitBreaker(doc.Name, s, opts...).(*circuitBreaker)
+	if err := c.restore(doc.State, doc.Pinned, timeOf(doc.OpenedAt), timeOf(doc.ChangedAt), doc.Counts); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,75 @@
+package go_circuit_breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestRebuiltBreakerHasEquivalentConfigAndState(t *testing.T) {
+	strategy := &Strategy{
+		Threshold:          3,
+		RetryInterval:      2,
+		Timeout:            time.Second,
+		CategoryThresholds: map[string]int{"timeout": 1},
+		ManualRecoveryOnly: true,
+	}
+	cb := NewCircuitBreaker("test", strategy)
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	for i := 0; i < 2; i++ {
+		cb.Execute(errFunc)
+	}
+
+	data, err := json.Marshal(cb)
+	assert.Equal(t, err, nil)
+
+	rebuilt, err := NewCircuitBreakerFromJSON(data, nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rebuilt.GetName(), "test")
+	assert.Equal(t, rebuilt.GetState(), Closed)
+	assert.Equal(t, rebuilt.Counts(), cb.Counts())
+	assert.Equal(t, encodeStrategy(rebuilt.(*circuitBreaker).strategy), encodeStrategy(strategy))
+
+	again, err := json.Marshal(rebuilt)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(again), string(data))
+
+	// the progress toward tripping is carried over
+	rebuilt.Execute(errFunc)
+	rebuilt.Execute(errFunc)
+	assert.Equal(t, rebuilt.GetState(), Open)
+}
+
+func TestRebuiltBreakerKeepsHooksOfStrategy(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, Clock: clock})
+	cb.ForceOpen("alice")
+
+	data, err := json.Marshal(cb)
+	assert.Equal(t, err, nil)
+
+	hooks := &Strategy{Threshold: 7, Fallback: func(err error) (interface{}, error) {
+		return "fallback", nil
+	}}
+	rebuilt, err := NewCircuitBreakerFromJSON(data, hooks)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hooks.Threshold, 7)
+	assert.Equal(t, rebuilt.GetState(), Open)
+	assert.Equal(t, rebuilt.(*circuitBreaker).openedAt, clock.Now())
+
+	res, err := rebuilt.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "fallback")
+}
+
+func TestWhenDocumentHasInvalidStateRebuildingFails(t *testing.T) {
+	_, err := NewCircuitBreakerFromJSON([]byte(`{"name":"test","state":7,"counts":{},"strategy":{}}`), nil)
+	assert.Equal(t, err, errors.New("invalid circuit breaker state 7"))
+}
@@ -0,0 +1,97 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// middleware guards an http handler with a circuit breaker
+type middleware struct {
+	cb            CircuitBreaker
+	next          http.Handler
+	openResponder func(w http.ResponseWriter, r *http.Request, err *OpenError)
+}
+
+// MiddlewareOption configures optional behaviour of Middleware
+type MiddlewareOption func(*middleware)
+
+// WithOpenResponder replaces the plain 503 written for requests rejected by the circuit breaker
+func WithOpenResponder(responder func(w http.ResponseWriter, r *http.Request, err *OpenError)) MiddlewareOption {
+	return func(m *middleware) {
+		m.openResponder = responder
+	}
+}
+
+// Middleware returns http middleware guarding handlers with the circuit breaker.
+// Responses with a 5xx status count as failures. Rejected requests get a 503 with a Retry-After header
+// when the circuit breaker recovers on its own, set to the next probe while half open and to the earliest
+// close while open. Handlers are never replayed, see ExecuteWithContext. Timeout and Fallback do not apply
+// to handlers
+func Middleware(cb CircuitBreaker, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		m := &middleware{cb: cb, next: next, openResponder: respondOpen}
+		for _, opt := range opts {
+			opt(m)
+		}
+		return m
+	}
+}
+
+// statusRecorder remembers the status written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// ServeHTTP implements http.Handler
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.Context().Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	p, err := allow(r.Context(), m.cb)
+	if err != nil {
+		var openErr *OpenError
+		if errors.As(err, &openErr) {
+			m.openResponder(w, r, openErr)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	m.next.ServeHTTP(rec, r)
+
+	err = nil
+	if rec.status >= http.StatusInternalServerError {
+		err = fmt.Errorf("handler responded with status %v", rec.status)
+	}
+	recordOutcome(m.cb, p, nil, err)
+}
+
+// respondOpen writes a plain 503 for a rejected request
+func respondOpen(w http.ResponseWriter, r *http.Request, err *OpenError) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
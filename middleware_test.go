@@ -0,0 +1,162 @@
+package go_circuit_breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareCountsServerErrors(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+	status := http.StatusOK
+	handler := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	status = http.StatusInternalServerError
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, rec.Code, http.StatusInternalServerError)
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+}
+
+func TestWhenOpenMiddlewareRespondsWithPlain503(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 30, Clock: clock})
+	handler := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	// the third request probes after the breaker tripped
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rec.Header().Get("Retry-After"), "30")
+	assert.Equal(t, rec.Body.String(), "circuit half open. trying to recover\n")
+}
+
+func TestWhenOpenMiddlewareUsesOpenResponder(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Logger: &recordingLogger{}})
+	cb.ForceOpen("test")
+
+	responder := func(w http.ResponseWriter, r *http.Request, err *OpenError) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Circuit-Breaker", err.Name)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	handler := Middleware(cb, WithOpenResponder(responder))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called while open")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, rec.Header().Get("X-Circuit-Breaker"), "test")
+	assert.Equal(t, rec.Body.String(), "{\"error\":\"test circuit breaker open\"}\n")
+}
+
+func TestRetryAfterIsShorterWhileHalfOpenThanWhileOpen(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:        1,
+		RetryInterval:    30,
+		RetryMax:         1,
+		SuccessThreshold: 3,
+		Clock:            clock,
+		Logger:           &recordingLogger{},
+		ProbeFunc: func() error {
+			return errors.New("still down")
+		},
+	})
+	handler := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	retryAfter := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header().Get("Retry-After")
+	}
+
+	retryAfter()
+	retryAfter()
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, retryAfter(), "30")
+
+	// recovering fails and the open breaker needs three successful probes to close
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Second * 30)
+		waitFor(t, func() bool { return clock.Pending() == 1 })
+	}
+	waitFor(t, func() bool { return cb.GetState() == Open })
+	assert.Equal(t, retryAfter(), "90")
+}
+
+func TestMiddlewareRecoversByPassingRequestsThroughAsProbes(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+	status := http.StatusBadGateway
+	calls := 0
+	handler := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+
+	serve()
+	serve()
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, clock.Pending(), 0)
+
+	status = http.StatusOK
+	assert.Equal(t, serve(), http.StatusOK)
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, calls, 3)
+}
+
+func TestResponseFinishingAfterTripDoesNotCloseBreaker(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	failing := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	close(release)
+	<-done
+	assert.Equal(t, cb.GetState(), HalfOpen)
+}
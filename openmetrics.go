@@ -0,0 +1,58 @@
+package go_circuit_breaker
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// openMetricsFamily describes a metric family and how to read its value from a circuit breaker
+type openMetricsFamily struct {
+	name       string
+	metricType string
+	help       string
+	value      func(cb CircuitBreaker, stats Stats) uint64
+}
+
+var openMetricsFamilies = []openMetricsFamily{
+	{"circuit_breaker_state", "gauge", "State of the circuit breaker: 1 closed, 2 half open, 3 open",
+		func(cb CircuitBreaker, stats Stats) uint64 { return uint64(cb.GetState()) }},
+	{"circuit_breaker_requests", "counter", "Executions admitted in closed state",
+		func(cb CircuitBreaker, stats Stats) uint64 { return stats.Requests }},
+	{"circuit_breaker_successes", "counter", "Successful executions",
+		func(cb CircuitBreaker, stats Stats) uint64 { return stats.Successes }},
+	{"circuit_breaker_failures", "counter", "Failed executions",
+		func(cb CircuitBreaker, stats Stats) uint64 { return stats.Failures }},
+	{"circuit_breaker_rejections", "counter", "Rejected executions",
+		func(cb CircuitBreaker, stats Stats) uint64 { return stats.Rejections }},
+	{"circuit_breaker_dropped_events", "counter", "Events dropped because of a full buffer",
+		func(cb CircuitBreaker, stats Stats) uint64 { return cb.DroppedEvents() }},
+}
+
+// WriteOpenMetrics writes the state and counters of the circuit breakers in OpenMetrics text format
+func WriteOpenMetrics(w io.Writer, cbs ...CircuitBreaker) error {
+	stats := make([]Stats, len(cbs))
+	for i, cb := range cbs {
+		stats[i] = cb.Stats()
+	}
+
+	var b strings.Builder
+	for _, family := range openMetricsFamilies {
+		fmt.Fprintf(&b, "# TYPE %v %v\n", family.name, family.metricType)
+		fmt.Fprintf(&b, "# HELP %v %v\n", family.name, family.help)
+
+		sample := family.name
+		if family.metricType == "counter" {
+			sample += "_total"
+		}
+		for i, cb := range cbs {
+			fmt.Fprintf(&b, "%v{name=\"%v\"} %v\n", sample, labelValueReplacer.Replace(cb.GetName()), family.value(cb, stats[i]))
+		}
+	}
+	b.WriteString("# EOF\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,71 @@
+package go_circuit_breaker
+
+import (
+	"bytes"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	openMetricsComment = regexp.MustCompile(`^# (TYPE [a-z_]+ (gauge|counter)|HELP [a-z_]+ .+)$`)
+	openMetricsSample  = regexp.MustCompile(`^[a-z_]+\{name="(\\.|[^"\\])*"\} [0-9]+$`)
+)
+
+// parseOpenMetrics validates the exposition and returns its samples
+func parseOpenMetrics(t *testing.T, text string) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	assert.Equal(t, lines[len(lines)-1], "# EOF")
+
+	var samples []string
+	for _, line := range lines[:len(lines)-1] {
+		switch {
+		case openMetricsComment.MatchString(line):
+		case openMetricsSample.MatchString(line):
+			samples = append(samples, line)
+		default:
+			t.Fatalf("invalid line %q", line)
+		}
+	}
+	return samples
+}
+
+func TestWriteOpenMetricsExposesStateAndCounters(t *testing.T) {
+	cb := NewCircuitBreaker("payments", &Strategy{Threshold: 1})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	happyFunc := func() (interface{}, error) {
+		return "yay", nil
+	}
+
+	cb.Execute(happyFunc)
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	var buf bytes.Buffer
+	err := WriteOpenMetrics(&buf, cb, NewCircuitBreaker(`quote"d`, &Strategy{}))
+	assert.Equal(t, err, nil)
+
+	samples := parseOpenMetrics(t, buf.String())
+	assert.Equal(t, samples, []string{
+		`circuit_breaker_state{name="payments"} 2`,
+		`circuit_breaker_state{name="quote\"d"} 1`,
+		`circuit_breaker_requests_total{name="payments"} 3`,
+		`circuit_breaker_requests_total{name="quote\"d"} 0`,
+		`circuit_breaker_successes_total{name="payments"} 1`,
+		`circuit_breaker_successes_total{name="quote\"d"} 0`,
+		`circuit_breaker_failures_total{name="payments"} 2`,
+		`circuit_breaker_failures_total{name="quote\"d"} 0`,
+		`circuit_breaker_rejections_total{name="payments"} 1`,
+		`circuit_breaker_rejections_total{name="quote\"d"} 0`,
+		`circuit_breaker_dropped_events_total{name="payments"} 0`,
+		`circuit_breaker_dropped_events_total{name="quote\"d"} 0`,
+	})
+}
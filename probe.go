@@ -0,0 +1,42 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPHealthProbe returns a probe requesting url which fails on transport errors and non 2xx responses.
+// Uses http.DefaultClient when client is nil. A timeout of zero or less only applies the timeout of the client
+func HTTPHealthProbe(url string, client *http.Client, timeout time.Duration) func() error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		}
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("health check %v responded with status %v", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package go_circuit_breaker
+
+import (
+	"fmt"
+	"github.com/magiconair/properties/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPHealthProbeFollowsHealthStatus(t *testing.T) {
+	var healthy int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	probe := HTTPHealthProbe(server.URL, server.Client(), time.Second)
+	assert.Equal(t, probe(), nil)
+
+	atomic.StoreInt32(&healthy, 0)
+	assert.Equal(t, probe(), fmt.Errorf("health check %v responded with status 503", server.URL))
+}
+
+func TestHTTPHealthProbeFailsOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	probe := HTTPHealthProbe(server.URL, server.Client(), time.Millisecond*10)
+	assert.Equal(t, probe() != nil, true)
+}
+
+func TestHTTPHealthProbeWithoutTimeoutWaitsForResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 10)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	probe := HTTPHealthProbe(server.URL, server.Client(), 0)
+	assert.Equal(t, probe(), nil)
+}
+
+func TestHTTPHealthProbeFailsOnTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	probe := HTTPHealthProbe(server.URL, nil, time.Second)
+	assert.Equal(t, probe() != nil, true)
+}
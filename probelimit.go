@@ -0,0 +1,65 @@
+package go_circuit_breaker
+
+import "sync/atomic"
+
+// probeLimiter bounds the recovery probes running at once across the circuit breakers sharing it
+type probeLimiter struct {
+	slots  chan struct{}
+	queued int64
+}
+
+func newProbeLimiter(max int) *probeLimiter {
+	return &probeLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire waits for a free slot and reports false when stop closed before
+func (l *probeLimiter) acquire(stop <-chan struct{}) bool {
+	atomic.AddInt64(&l.queued, 1)
+	defer atomic.AddInt64(&l.queued, -1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// tryAcquire takes a free slot without waiting and reports whether there was one
+func (l *probeLimiter) tryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *probeLimiter) release() {
+	<-l.slots
+}
+
+// withProbeLimiter makes the recovery probes of the circuit breaker wait for a slot of l. Executions admitted
+// as probes do not wait but are rejected while all slots are taken
+func withProbeLimiter(l *probeLimiter) Option {
+	return func(c *circuitBreaker) {
+		c.probeLimiter = l
+	}
+}
+
+// ActiveProbes returns the probes currently running across all circuit breakers of the registry.
+// Only tracked when the strategy sets MaxConcurrentProbes
+func (r *Registry) ActiveProbes() int {
+	if r.probes == nil {
+		return 0
+	}
+	return len(r.probes.slots)
+}
+
+// QueuedProbes returns the recovery probes waiting for one of the MaxConcurrentProbes slots
+func (r *Registry) QueuedProbes() int {
+	if r.probes == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&r.probes.queued))
+}
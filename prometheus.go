@@ -0,0 +1,85 @@
+package go_circuit_breaker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var labelNamePattern = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// CollectorOpts configures the metric names and labels of a Collector
+type CollectorOpts struct {
+	Namespace   string
+	Subsystem   string
+	ConstLabels map[string]string
+}
+
+// Collector exports the circuit breakers of a registry as Prometheus metrics
+type Collector struct {
+	registry      *Registry
+	state         *prometheus.Desc
+	droppedEvents *prometheus.Desc
+	latency       *prometheus.Desc
+}
+
+// NewCollector returns new collector for all circuit breakers of the registry
+func NewCollector(reg *Registry, opts CollectorOpts) (*Collector, error) {
+	for label := range opts.ConstLabels {
+		if !labelNamePattern.MatchString(label) || strings.HasPrefix(label, "__") {
+			return nil, fmt.Errorf("invalid label name %q", label)
+		}
+		if label == "name" {
+			return nil, fmt.Errorf("label name %q is reserved for the circuit breaker name", label)
+		}
+	}
+
+	desc := func(name, help string) *prometheus.Desc {
+		fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, name)
+		return prometheus.NewDesc(fqName, help, []string{"name"}, opts.ConstLabels)
+	}
+
+	return &Collector{
+		registry:      reg,
+		state:         desc("circuit_breaker_state", "State of the circuit breaker: 1 closed, 2 half open, 3 open"),
+		droppedEvents: desc("circuit_breaker_dropped_events_total", "Events dropped because of a full buffer"),
+		latency:       desc("circuit_breaker_latency_seconds", "Latency of executions"),
+	}, nil
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	ch <- c.droppedEvents
+	ch <- c.latency
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, cb := range c.registry.snapshot() {
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, float64(cb.GetState()), cb.GetName())
+		ch <- prometheus.MustNewConstMetric(c.droppedEvents, prometheus.CounterValue, float64(cb.DroppedEvents()), cb.GetName())
+
+		stats := cb.Stats()
+		buckets := make(map[float64]uint64, len(stats.Latency))
+		var cumulative uint64
+		for _, bucket := range stats.Latency {
+			cumulative += bucket.Count
+			buckets[bucket.UpperBound.Seconds()] = cumulative
+		}
+		ch <- prometheus.MustNewConstHistogram(c.latency, stats.LatencyCount, stats.LatencySum.Seconds(), buckets, cb.GetName())
+	}
+}
+
+// PushMetrics pushes the current metrics of all circuit breakers of the registry to the Prometheus Pushgateway
+// at url, replacing the metrics previously pushed for job. Suits batch jobs without a scrape endpoint
+func PushMetrics(url, job string, reg *Registry) error {
+	collector, err := NewCollector(reg, CollectorOpts{})
+	if err != nil {
+		return err
+	}
+	return push.New(url, job).Collector(collector).Push()
+}
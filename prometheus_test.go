@@ -0,0 +1,131 @@
+package go_circuit_breaker
+
+import (
+	"bufio"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollectorUsesCustomNamesAndLabels(t *testing.T) {
+	reg := NewRegistry(&Strategy{})
+	reg.Get("payments").(*circuitBreaker).state = Open
+
+	collector, err := NewCollector(reg, CollectorOpts{
+		Namespace:   "shop",
+		Subsystem:   "checkout",
+		ConstLabels: map[string]string{"service": "api", "environment": "prod"},
+	})
+	assert.Equal(t, err, nil)
+
+	promReg := prometheus.NewRegistry()
+	promReg.MustRegister(collector)
+
+	families, err := promReg.Gather()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(families), 3)
+	assert.Equal(t, families[0].GetName(), "shop_checkout_circuit_breaker_dropped_events_total")
+	assert.Equal(t, families[1].GetName(), "shop_checkout_circuit_breaker_latency_seconds")
+	assert.Equal(t, families[2].GetName(), "shop_checkout_circuit_breaker_state")
+
+	metric := families[2].GetMetric()[0]
+	assert.Equal(t, metric.GetGauge().GetValue(), float64(Open))
+
+	labels := make(map[string]string)
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, labels, map[string]string{"name": "payments", "service": "api", "environment": "prod"})
+}
+
+func TestCollectorRejectsInvalidLabelNames(t *testing.T) {
+	reg := NewRegistry(&Strategy{})
+
+	_, err := NewCollector(reg, CollectorOpts{ConstLabels: map[string]string{"1service": "api"}})
+	assert.Equal(t, err, errors.New(`invalid label name "1service"`))
+
+	_, err = NewCollector(reg, CollectorOpts{ConstLabels: map[string]string{"__internal": "api"}})
+	assert.Equal(t, err, errors.New(`invalid label name "__internal"`))
+
+	_, err = NewCollector(reg, CollectorOpts{ConstLabels: map[string]string{"name": "api"}})
+	assert.Equal(t, err, errors.New(`label name "name" is reserved for the circuit breaker name`))
+}
+
+func TestCollectorExportsLatencyHistogramWithCustomBuckets(t *testing.T) {
+	reg := NewRegistry(&Strategy{LatencyBuckets: []time.Duration{time.Millisecond * 10, time.Second}})
+	cb := reg.Get("payments")
+	recordLatencies(cb, time.Millisecond*5, 2)
+	recordLatencies(cb, time.Millisecond*500, 1)
+	recordLatencies(cb, time.Second*2, 1)
+
+	collector, err := NewCollector(reg, CollectorOpts{})
+	assert.Equal(t, err, nil)
+
+	promReg := prometheus.NewRegistry()
+	promReg.MustRegister(collector)
+
+	families, err := promReg.Gather()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, families[1].GetName(), "circuit_breaker_latency_seconds")
+
+	histogram := families[1].GetMetric()[0].GetHistogram()
+	assert.Equal(t, histogram.GetSampleCount(), uint64(4))
+	assert.Equal(t, histogram.GetBucket()[0].GetUpperBound(), 0.01)
+	assert.Equal(t, histogram.GetBucket()[0].GetCumulativeCount(), uint64(2))
+	assert.Equal(t, histogram.GetBucket()[1].GetUpperBound(), float64(1))
+	assert.Equal(t, histogram.GetBucket()[1].GetCumulativeCount(), uint64(3))
+}
+
+func TestPushMetricsPushesCircuitBreakers(t *testing.T) {
+	var method, path string
+	families := make(map[string]*dto.MetricFamily)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		// a buffered body keeps the decoder from losing families to a fresh buffer per call
+		decoder := expfmt.NewDecoder(bufio.NewReader(r.Body), expfmt.ResponseFormat(r.Header))
+		for {
+			family := &dto.MetricFamily{}
+			if err := decoder.Decode(family); err != nil {
+				break
+			}
+			families[family.GetName()] = family
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	reg := NewRegistry(&Strategy{})
+	reg.Get("payments").(*circuitBreaker).state = Open
+	reg.Get("orders")
+
+	assert.Equal(t, PushMetrics(gateway.URL, "nightly", reg), nil)
+	assert.Equal(t, method, http.MethodPut)
+	assert.Equal(t, path, "/metrics/job/nightly")
+	assert.Equal(t, len(families), 3)
+
+	states := make(map[string]float64)
+	for _, metric := range families["circuit_breaker_state"].GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "name" {
+				states[label.GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, states, map[string]float64{"payments": float64(Open), "orders": float64(Closed)})
+}
+
+func TestWhenPushgatewayFailsPushMetricsReturnsError(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer gateway.Close()
+
+	err := PushMetrics(gateway.URL, "nightly", NewRegistry(&Strategy{}))
+	assert.Equal(t, err != nil, true)
+}
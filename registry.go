@@ -0,0 +1,233 @@
+package go_circuit_breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowName is the name of the circuit breaker shared by all lookups beyond MaxKeys. The name is reserved:
+// looking it up or registering under it always refers to that shared circuit breaker
+const OverflowName = "<overflow>"
+
+// Registry holds named circuit breakers sharing a single strategy
+type Registry struct {
+	mu       sync.Mutex
+	strategy *Strategy
+	breakers map[string]CircuitBreaker
+	lastUsed map[string]time.Time
+	// recency orders lookups for evicting the least recently used circuit breaker
+	recency  map[string]uint64
+	lookups  uint64
+	overflow CircuitBreaker
+	// weights of circuit breakers in the health score. Defaults to 1
+	weights map[string]float64
+	// probes is shared by the circuit breakers created when MaxConcurrentProbes is set
+	probes  *probeLimiter
+	stopped bool
+}
+
+// NewRegistry returns new registry creating circuit breakers with the given strategy.
+// Starts evicting idle circuit breakers when the strategy has an IdleTimeout
+func NewRegistry(strategy *Strategy) *Registry {
+	if strategy.Clock == nil {
+		strategy.Clock = realClock{}
+	}
+
+	r := &Registry{
+		strategy: strategy,
+		breakers: make(map[string]CircuitBreaker),
+		lastUsed: make(map[string]time.Time),
+		recency:  make(map[string]uint64),
+		weights:  make(map[string]float64),
+	}
+	if strategy.MaxConcurrentProbes > 0 {
+		r.probes = newProbeLimiter(strategy.MaxConcurrentProbes)
+	}
+
+	if strategy.IdleTimeout > 0 {
+		strategy.Clock.AfterFunc(strategy.IdleTimeout, r.sweep)
+	}
+	return r
+}
+
+// Stop stops evicting idle circuit breakers and stops every circuit breaker of the registry
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+
+	for _, cb := range r.snapshot() {
+		cb.Stop()
+	}
+}
+
+// Get returns the circuit breaker registered under name and creates it on first use.
+// Beyond MaxKeys it returns the shared overflow circuit breaker when no closed one can be evicted
+func (r *Registry) Get(name string) CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cb, ok := r.breakers[name]
+	if !ok {
+		if name == OverflowName || r.strategy.MaxKeys > 0 && len(r.breakers) >= r.strategy.MaxKeys && !r.evictLeastRecentlyUsed() {
+			if r.overflow == nil {
+				r.overflow = r.create(OverflowName)
+			}
+			return r.overflow
+		}
+		cb = r.create(name)
+		r.breakers[name] = cb
+	}
+	r.touch(name)
+	return cb
+}
+
+// create returns new circuit breaker with the strategy of the registry
+func (r *Registry) create(name string) CircuitBreaker {
+	if r.probes != nil {
+		return NewCircuitBreaker(name, r.strategy, withProbeLimiter(r.probes))
+	}
+	return NewCircuitBreaker(name, r.strategy)
+}
+
+// Register adds an existing circuit breaker under its name, replacing any previous one.
+// Its probes count toward MaxConcurrentProbes unless it already has a limit of its own
+func (r *Registry) Register(cb CircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := cb.(*circuitBreaker); ok && r.probes != nil {
+		c.mu.Lock()
+		if c.probeLimiter == nil {
+			c.probeLimiter = r.probes
+		}
+		c.mu.Unlock()
+	}
+	if cb.GetName() == OverflowName {
+		r.overflow = cb
+		return
+	}
+
+	r.breakers[cb.GetName()] = cb
+	r.touch(cb.GetName())
+}
+
+// RegisterWeighted adds an existing circuit breaker like Register, weighing it in the health score
+func (r *Registry) RegisterWeighted(cb CircuitBreaker, weight float64) {
+	r.Register(cb)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.weights[cb.GetName()] = weight
+}
+
+// HealthScore returns the weighted share of healthy circuit breakers from 0 to 1.
+// Closed ones count fully, half open ones half and open ones not at all. Returns 1 without circuit breakers
+func (r *Registry) HealthScore() float64 {
+	cbs := r.snapshot()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var score, total float64
+	for _, cb := range cbs {
+		weight, ok := r.weights[cb.GetName()]
+		if !ok {
+			weight = 1
+		}
+
+		total += weight
+		switch cb.GetState() {
+		case Closed:
+			score += weight
+		case HalfOpen:
+			score += weight / 2
+		}
+	}
+
+	if total == 0 {
+		return 1
+	}
+	return score / total
+}
+
+// touch marks the circuit breaker as used. Must be called with the lock held
+func (r *Registry) touch(name string) {
+	r.lookups++
+	r.lastUsed[name] = r.strategy.Clock.Now()
+	r.recency[name] = r.lookups
+}
+
+// evictLeastRecentlyUsed removes the least recently used closed circuit breaker and reports whether
+// one was removed. Others are kept to not lose their protection. Must be called with the lock held
+func (r *Registry) evictLeastRecentlyUsed() bool {
+	victim, oldest, found := "", uint64(0), false
+	for name, cb := range r.breakers {
+		if cb.GetState() != Closed {
+			continue
+		}
+		if !found || r.recency[name] < oldest {
+			victim, oldest, found = name, r.recency[name], true
+		}
+	}
+
+	if !found {
+		return false
+	}
+	r.remove(victim)
+	return true
+}
+
+// remove deletes and stops the circuit breaker. Must be called with the lock held
+func (r *Registry) remove(name string) {
+	r.breakers[name].Stop()
+	delete(r.breakers, name)
+	delete(r.lastUsed, name)
+	delete(r.recency, name)
+}
+
+// Filter returns all registered circuit breakers matching pred
+func (r *Registry) Filter(pred func(Inspector) bool) []Inspector {
+	var matches []Inspector
+	for _, cb := range r.snapshot() {
+		if pred(cb) {
+			matches = append(matches, cb)
+		}
+	}
+	return matches
+}
+
+// snapshot returns all registered circuit breakers
+func (r *Registry) snapshot() []CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cbs := make([]CircuitBreaker, 0, len(r.breakers)+1)
+	for _, cb := range r.breakers {
+		cbs = append(cbs, cb)
+	}
+	if r.overflow != nil {
+		cbs = append(cbs, r.overflow)
+	}
+	return cbs
+}
+
+// sweep removes circuit breakers which were not looked up within the idle timeout
+func (r *Registry) sweep() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return
+	}
+
+	now := r.strategy.Clock.Now()
+	for name, used := range r.lastUsed {
+		if now.Sub(used) >= r.strategy.IdleTimeout {
+			r.remove(name)
+		}
+	}
+
+	r.strategy.Clock.AfterFunc(r.strategy.IdleTimeout, r.sweep)
+}
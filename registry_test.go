@@ -0,0 +1,252 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFilterReturnsOnlyMatchingBreakers(t *testing.T) {
+	reg := NewRegistry(&Strategy{})
+
+	open := NewCircuitBreaker("open", &Strategy{})
+	open.(*circuitBreaker).state = Open
+	halfOpen := NewCircuitBreaker("half-open", &Strategy{})
+	halfOpen.(*circuitBreaker).state = HalfOpen
+
+	reg.Register(open)
+	reg.Register(halfOpen)
+	reg.Get("closed")
+
+	isOpen := func(i Inspector) bool {
+		return i.GetState() == Open
+	}
+
+	matches := reg.Filter(isOpen)
+	assert.Equal(t, len(matches), 1)
+	assert.Equal(t, matches[0].GetName(), "open")
+}
+
+func TestFilterReturnsNothingWithoutMatches(t *testing.T) {
+	reg := NewRegistry(&Strategy{})
+	reg.Get("closed")
+
+	isOpen := func(i Inspector) bool {
+		return i.GetState() == Open
+	}
+
+	assert.Equal(t, len(reg.Filter(isOpen)), 0)
+}
+
+func TestWhenBreakerIsIdleItIsEvicted(t *testing.T) {
+	clock := newFakeClock()
+	reg := NewRegistry(&Strategy{IdleTimeout: time.Minute, Clock: clock})
+	defer reg.Stop()
+
+	all := func(i Inspector) bool {
+		return true
+	}
+
+	reg.Get("idle")
+	reg.Get("busy")
+
+	clock.Advance(time.Second * 30)
+	reg.Get("busy")
+
+	// first sweep only evicts the idle breaker
+	clock.Advance(time.Second * 30)
+	matches := reg.Filter(all)
+	assert.Equal(t, len(matches), 1)
+	assert.Equal(t, matches[0].GetName(), "busy")
+
+	// second sweep evicts the now idle busy breaker
+	clock.Advance(time.Minute)
+	assert.Equal(t, len(reg.Filter(all)), 0)
+}
+
+func TestEvictedBreakersAreStopped(t *testing.T) {
+	clock := newFakeClock()
+	reg := NewRegistry(&Strategy{IdleTimeout: time.Minute, MaxKeys: 2, Clock: clock})
+	defer reg.Stop()
+
+	first := reg.Get("first").(*circuitBreaker)
+	second := reg.Get("second").(*circuitBreaker)
+
+	// evicted as least recently used
+	reg.Get("third")
+	assert.Equal(t, first.stopped(), true)
+	assert.Equal(t, second.stopped(), false)
+
+	// evicted as idle
+	clock.Advance(time.Minute)
+	assert.Equal(t, second.stopped(), true)
+}
+
+func TestStoppingRegistryStopsItsBreakers(t *testing.T) {
+	reg := NewRegistry(&Strategy{MaxKeys: 1})
+
+	first := reg.Get("first").(*circuitBreaker)
+	first.state = Open
+	overflow := reg.Get("second").(*circuitBreaker)
+	registered := NewCircuitBreaker("registered", &Strategy{}).(*circuitBreaker)
+	reg.Register(registered)
+
+	reg.Stop()
+	assert.Equal(t, first.stopped(), true)
+	assert.Equal(t, overflow.stopped(), true)
+	assert.Equal(t, registered.stopped(), true)
+}
+
+func TestWhenEvictedBreakerIsLookedUpItStartsClosed(t *testing.T) {
+	clock := newFakeClock()
+	reg := NewRegistry(&Strategy{IdleTimeout: time.Minute, Clock: clock})
+	defer reg.Stop()
+
+	reg.Get("test").(*circuitBreaker).state = Open
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, reg.Get("test").GetState(), Closed)
+}
+
+func TestWhenMaxKeysIsReachedLeastRecentlyUsedBreakerIsEvicted(t *testing.T) {
+	reg := NewRegistry(&Strategy{MaxKeys: 2})
+
+	all := func(i Inspector) bool {
+		return true
+	}
+
+	reg.Get("first")
+	reg.Get("second")
+	reg.Get("first")
+	reg.Get("third")
+
+	names := map[string]bool{}
+	for _, cb := range reg.Filter(all) {
+		names[cb.GetName()] = true
+	}
+	assert.Equal(t, names, map[string]bool{"first": true, "third": true})
+}
+
+func TestWhenMaxKeysIsReachedWithoutClosedBreakersOverflowIsShared(t *testing.T) {
+	reg := NewRegistry(&Strategy{MaxKeys: 2})
+
+	reg.Get("first").(*circuitBreaker).state = Open
+	reg.Get("second").(*circuitBreaker).state = HalfOpen
+
+	third := reg.Get("third")
+	fourth := reg.Get("fourth")
+	assert.Equal(t, third.GetName(), OverflowName)
+	assert.Equal(t, third == fourth, true)
+
+	// tripped breakers keep their protection
+	assert.Equal(t, reg.Get("first").GetState(), Open)
+	assert.Equal(t, reg.Get("second").GetState(), HalfOpen)
+}
+
+func TestOverflowNameIsReserved(t *testing.T) {
+	reg := NewRegistry(&Strategy{MaxKeys: 1})
+
+	reg.Get("overflow").(*circuitBreaker).state = Open
+	overflow := reg.Get("second")
+	assert.Equal(t, overflow.GetName(), OverflowName)
+	assert.Equal(t, reg.Get("overflow") == overflow, false)
+
+	// looking up the reserved name returns the shared overflow breaker instead of a new one
+	assert.Equal(t, reg.Get(OverflowName) == overflow, true)
+	assert.Equal(t, len(reg.Filter(func(i Inspector) bool { return true })), 2)
+}
+
+func TestHealthScoreWeighsBreakerStates(t *testing.T) {
+	reg := NewRegistry(&Strategy{})
+	assert.Equal(t, reg.HealthScore(), 1.0)
+
+	payments := NewCircuitBreaker("payments", &Strategy{})
+	payments.(*circuitBreaker).state = Open
+	search := NewCircuitBreaker("search", &Strategy{})
+	search.(*circuitBreaker).state = HalfOpen
+
+	reg.RegisterWeighted(payments, 5)
+	reg.RegisterWeighted(search, 2)
+	reg.Get("avatars")
+
+	// (0*5 + 0.5*2 + 1*1) / 8
+	assert.Equal(t, reg.HealthScore(), 0.25)
+}
+
+func TestRecoveryProbesAcrossRegistryRespectConcurrencyCap(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	var running, maxRunning int32
+	reg := NewRegistry(&Strategy{
+		Threshold:           1,
+		RetryInterval:       1,
+		MaxConcurrentProbes: 2,
+		Clock:               clock,
+		ProbeFunc: func() error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			<-release
+			return nil
+		},
+	})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		reg.Get(name).Execute(errFunc)
+		reg.Get(name).Execute(errFunc)
+	}
+
+	waitFor(t, func() bool { return clock.Pending() == len(names) })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return reg.ActiveProbes() == 2 && reg.QueuedProbes() == 3 })
+
+	close(release)
+	for _, name := range names {
+		cb := reg.Get(name)
+		waitFor(t, func() bool { return cb.GetState() == Closed })
+	}
+	assert.Equal(t, atomic.LoadInt32(&maxRunning), int32(2))
+	assert.Equal(t, reg.ActiveProbes(), 0)
+	assert.Equal(t, reg.QueuedProbes(), 0)
+}
+
+func TestExecutionsAdmittedAsProbesRespectConcurrencyCap(t *testing.T) {
+	reg := NewRegistry(&Strategy{Threshold: 1, MaxConcurrentProbes: 1, SoftOpenSampleRate: 1})
+
+	first := reg.Get("first")
+	registered := NewCircuitBreaker("registered", &Strategy{Threshold: 1})
+	reg.Register(registered)
+	for _, cb := range []CircuitBreaker{first, registered} {
+		for i := 0; i < 2; i++ {
+			cb.Allow()
+			cb.Record(false)
+		}
+		assert.Equal(t, cb.GetState(), HalfOpen)
+	}
+	sampled := reg.Get("sampled")
+	sampled.(*circuitBreaker).state = Open
+
+	assert.Equal(t, first.Allow(), true)
+	assert.Equal(t, reg.ActiveProbes(), 1)
+	assert.Equal(t, registered.Allow(), false)
+	assert.Equal(t, sampled.Allow(), false)
+
+	// the slot is free again once the probe reported its outcome
+	first.Record(false)
+	assert.Equal(t, reg.ActiveProbes(), 0)
+	assert.Equal(t, registered.Allow(), true)
+	registered.Record(true)
+	assert.Equal(t, registered.GetState(), Closed)
+	assert.Equal(t, sampled.Allow(), true)
+}
@@ -0,0 +1,30 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryExecute executes f through the circuit breaker up to attempts times, waiting backoff between failed attempts.
+// Stops as soon as the circuit breaker rejects an execution and returns its *OpenError
+func RetryExecute(cb CircuitBreaker, attempts int, backoff time.Duration, f func() (interface{}, error)) (interface{}, error) {
+	var res interface{}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		res, err = cb.ExecuteWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+			return f()
+		})
+
+		var openErr *OpenError
+		if err == nil || errors.As(err, &openErr) {
+			return res, err
+		}
+
+		// no need to wait when the next attempt is rejected anyway
+		if cb.GetState() == Closed && attempt < attempts-1 {
+			time.Sleep(backoff)
+		}
+	}
+	return res, err
+}
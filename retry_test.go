@@ -0,0 +1,58 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestWhenBreakerOpensRetriesStop(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	executions := 0
+	errFunc := func() (interface{}, error) {
+		executions++
+		return nil, errors.New("i like to fail")
+	}
+
+	_, err := RetryExecute(cb, 5, time.Millisecond, errFunc)
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.State, HalfOpen)
+	// the third attempt probes the tripped dependency
+	assert.Equal(t, executions, 3)
+}
+
+func TestWhenRetrySucceedsResultIsReturned(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 3})
+
+	executions := 0
+	testFunc := func() (interface{}, error) {
+		executions++
+		if executions < 3 {
+			return nil, errors.New("i like to fail")
+		}
+		return "yay", nil
+	}
+
+	res, err := RetryExecute(cb, 5, time.Millisecond, testFunc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestWhenAttemptsRunOutLastErrorIsReturned(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5})
+
+	executions := 0
+	errFunc := func() (interface{}, error) {
+		executions++
+		return nil, errors.New("i like to fail")
+	}
+
+	_, err := RetryExecute(cb, 3, time.Millisecond, errFunc)
+	assert.Equal(t, err, errors.New("i like to fail"))
+	assert.Equal(t, executions, 3)
+}
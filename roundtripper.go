@@ -0,0 +1,72 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusError reports a response with a server error status
+type StatusError struct {
+	Response *http.Response
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded with status %v", e.Response.StatusCode)
+}
+
+// roundTripper guards requests of the next round tripper with a circuit breaker
+type roundTripper struct {
+	cb   CircuitBreaker
+	next http.RoundTripper
+}
+
+// NewRoundTripper returns a round tripper guarding next with the circuit breaker.
+// Transport errors without response and responses with a 5xx status both count as failures.
+// Status failures still pass the response to the caller. A fallback serving rejected requests has to return
+// an *http.Response, otherwise the request fails with *OpenError. Uses http.DefaultTransport when next is nil.
+//
+// To test code using it without a server, pass a function based round tripper as next which returns
+// the response or error to inject
+func NewRoundTripper(cb CircuitBreaker, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{cb: cb, next: next}
+}
+
+// RoundTrip implements http.RoundTripper. The request is sent at most once, see ExecuteWithContext
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := rt.cb.ExecuteWithContext(req.Context(), func(ctx context.Context) (interface{}, error) {
+		resp, err := rt.next.RoundTrip(req)
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			err = &StatusError{Response: resp}
+		}
+		return resp, err
+	})
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// a fallback may serve anything, only responses can be passed on
+	if resp, ok := res.(*http.Response); ok && resp != nil {
+		return resp, nil
+	}
+	return nil, rejectionOf(rt.cb)
+}
+
+// NewHTTPClient returns a copy of base sending its requests through a round tripper guarded by the
+// circuit breaker. Uses a default client when base is nil
+func NewHTTPClient(cb CircuitBreaker, base *http.Client) *http.Client {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	client.Transport = NewRoundTripper(cb, client.Transport)
+	return client
+}
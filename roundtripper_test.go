@@ -0,0 +1,163 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc injects responses and errors in place of a transport
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestRoundTripperCountsTransportErrorsAndServerErrors(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1})
+
+	transportErr := errors.New("connection refused")
+	results := []func() (*http.Response, error){
+		func() (*http.Response, error) { return nil, transportErr },
+		func() (*http.Response, error) { return response(http.StatusServiceUnavailable), nil },
+	}
+	requests := 0
+	client := &http.Client{Transport: NewRoundTripper(cb, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return results[requests-1]()
+	}))}
+
+	// transport error is returned as error
+	_, err := client.Get("http://dependency")
+	assert.Equal(t, errors.Is(err, transportErr), true)
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+
+	// server error is returned as response
+	resp, err := client.Get("http://dependency")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, cb.Stats().Failures, uint64(2))
+
+	// both failures tripped the breaker without sending another request
+	assert.Equal(t, cb.GetState(), HalfOpen)
+	assert.Equal(t, requests, 2)
+}
+
+func TestWhenRoundTripperTripsNextRequestsProbeUntilRecovered(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1, RetryInterval: 1, Clock: clock})
+
+	status := http.StatusServiceUnavailable
+	requests := 0
+	client := &http.Client{Transport: NewRoundTripper(cb, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return response(status), nil
+	}))}
+
+	client.Get("http://dependency")
+	client.Get("http://dependency")
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// the next request is sent as probe and the one after waits for the retry interval
+	resp, err := client.Get("http://dependency")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+	_, err = client.Get("http://dependency")
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, requests, 3)
+
+	status = http.StatusOK
+	clock.Advance(time.Second)
+	resp, err = client.Get("http://dependency")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, requests, 4)
+}
+
+func TestRoundTripperSendsSuccessfulRequestsOnce(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+
+	requests := 0
+	rt := NewRoundTripper(cb, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return response(http.StatusOK), nil
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://dependency", nil)
+	resp, err := rt.RoundTrip(req)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, requests, 1)
+	assert.Equal(t, cb.Stats().Successes, uint64(1))
+}
+
+func TestWhenOpenHTTPClientShortCircuits(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Logger: &recordingLogger{}})
+
+	requests := 0
+	base := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return response(http.StatusOK), nil
+	})}
+	client := NewHTTPClient(cb, base)
+
+	resp, err := client.Get("http://dependency")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	cb.ForceOpen("test")
+	_, err = client.Get("http://dependency")
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, requests, 1)
+
+	// base client keeps its transport
+	_, isGuarded := base.Transport.(*roundTripper)
+	assert.Equal(t, isGuarded, false)
+}
+
+func TestWhenOpenRoundTripperServesFallbackResponses(t *testing.T) {
+	fallback := response(http.StatusOK)
+	cb := NewCircuitBreaker("test", &Strategy{
+		Logger: &recordingLogger{},
+		Fallback: func(err error) (interface{}, error) {
+			return fallback, nil
+		},
+	})
+	cb.ForceOpen("test")
+
+	client := NewHTTPClient(cb, &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("request sent while open")
+		return nil, nil
+	})})
+	resp, err := client.Get("http://dependency")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestWhenFallbackServesNoResponseRoundTripperFailsWithOpenError(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{
+		Logger: &recordingLogger{},
+		Fallback: func(err error) (interface{}, error) {
+			return nil, nil
+		},
+	})
+	cb.ForceOpen("test")
+
+	_, err := NewHTTPClient(cb, &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("request sent while open")
+		return nil, nil
+	})}).Get("http://dependency")
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, openErr.State, Open)
+}
@@ -0,0 +1,12 @@
+package go_circuit_breaker
+
+import "golang.org/x/sync/semaphore"
+
+// WithWeightedSemaphore bounds concurrent executions through ExecuteWithContext by sem, each holding weight.
+// Callers wait for a slot until their context is done. The semaphore may be shared between circuit breakers
+func WithWeightedSemaphore(sem *semaphore.Weighted, weight int64) Option {
+	return func(c *circuitBreaker) {
+		c.semaphore = sem
+		c.semaphoreWeight = weight
+	}
+}
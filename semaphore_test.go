@@ -0,0 +1,59 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"github.com/magiconair/properties/assert"
+	"golang.org/x/sync/semaphore"
+	"testing"
+	"time"
+)
+
+func TestWhenWaitingForSemaphoreIsCancelledContextErrorIsReturned(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1}, WithWeightedSemaphore(semaphore.NewWeighted(2), 2))
+
+	acquired := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.ExecuteWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+			close(acquired)
+			<-release
+			return "yay", nil
+		})
+	}()
+	<-acquired
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	ran := false
+	_, err := cb.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+		ran = true
+		return "yay", nil
+	})
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, ran, false)
+	assert.Equal(t, cb.Stats().Failures, uint64(0))
+
+	close(release)
+	<-done
+	res, err := cb.ExecuteWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+}
+
+func TestWhenDeadlinePassesWaitingForSemaphoreContextErrorIsReturned(t *testing.T) {
+	sem := semaphore.NewWeighted(1)
+	sem.Acquire(context.Background(), 1)
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 1}, WithWeightedSemaphore(sem, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cb.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, cb.GetState(), Closed)
+}
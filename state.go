@@ -0,0 +1,143 @@
+package go_circuit_breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// stateVersion is the version of the encoding written by EncodeState. Version 1 did not hold the pin
+// of forced states yet and is still decoded
+const stateVersion = 2
+
+// ErrStateVersion is returned when decoding a state written by an unsupported version
+var ErrStateVersion = errors.New("unsupported circuit breaker state version")
+
+// errUnknownBreaker is returned for circuit breakers not created by NewCircuitBreaker
+var errUnknownBreaker = errors.New("circuit breaker not created by NewCircuitBreaker")
+
+// encodedState is the versioned encoding of a circuit breaker state
+type encodedState struct {
+	Version int   `json:"version"`
+	State   State `json:"state"`
+	// Pinned keeps a state forced by an operator held after restoring
+	Pinned   bool       `json:"pinned,omitempty"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	// ChangedAt lets a restored recovery wait only for the rest of the retry interval
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+	Counts    Counts     `json:"counts"`
+}
+
+// EncodeState encodes the state, counts and opening time of the circuit breaker,
+// letting a standby instance take over without a cold start
+func EncodeState(cb CircuitBreaker) ([]byte, error) {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		return nil, errUnknownBreaker
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.encodeState()
+}
+
+// encodeState encodes the state. Must be called with the lock held
+func (c *circuitBreaker) encodeState() ([]byte, error) {
+	return json.Marshal(encodedState{
+		Version:   stateVersion,
+		State:     c.state,
+		Pinned:    c.pinned,
+		OpenedAt:  optionalTime(c.openedAt),
+		ChangedAt: optionalTime(c.changedAt),
+		Counts:    c.counts,
+	})
+}
+
+// DecodeState restores a state encoded by EncodeState into the circuit breaker.
+// A restored HalfOpen state recovers like one set by WithInitialState unless a probe function is configured.
+// Forced states stay held until Reset
+func DecodeState(cb CircuitBreaker, data []byte) error {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		return errUnknownBreaker
+	}
+
+	var state encodedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Version < 1 || state.Version > stateVersion {
+		return fmt.Errorf("%w: %v", ErrStateVersion, state.Version)
+	}
+	return c.restore(state.State, state.Pinned, timeOf(state.OpenedAt), timeOf(state.ChangedAt), state.Counts)
+}
+
+// optionalTime returns nil for the zero time so it is omitted from encodings
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// timeOf returns the time t points to or the zero time when nil
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// restore sets the state, opening time and counts and resumes recovering from a state other than Closed
+// unless pinned. Open without a way out is restored as HalfOpen. With the time of the last state change known
+// the first probe only waits for the rest of the retry interval
+func (c *circuitBreaker) restore(to State, pinned bool, openedAt, changedAt time.Time, counts Counts) error {
+	if to < Closed || to > Open {
+		return fmt.Errorf("invalid circuit breaker state %v", int(to))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if to == Open && !pinned && !c.hasProbe() && !c.leavesOpen() {
+		// resume like after a trip instead of staying open for good
+		to = HalfOpen
+	}
+	c.pinned = pinned && to != Closed
+	c.resetCounts()
+	c.counts = counts
+	if to == Closed {
+		// carry the progress toward tripping over
+		now := c.strategy.Clock.Now()
+		for i := uint64(0); i < counts.ConsecutiveFailures; i++ {
+			c.counter.Record(false, now)
+		}
+	}
+
+	if to != c.state {
+		c.setState(to, auto("state restored"))
+	}
+	c.openedAt = openedAt
+	if !changedAt.IsZero() {
+		c.changedAt = changedAt
+	}
+
+	switch {
+	case to == Closed || c.pinned || c.strategy.ManualRecoveryOnly:
+	case c.hasProbe() && !c.stopped():
+		if !changedAt.IsZero() {
+			c.cooldownUntil = changedAt.Add(time.Second * time.Duration(c.strategy.RetryInterval))
+		}
+		c.spawn(func() {
+			c.recover(nil)
+		})
+	default:
+		c.callerProbes = to == HalfOpen
+		if !changedAt.IsZero() {
+			c.lastProbe = changedAt
+		}
+	}
+	return nil
+}
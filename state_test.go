@@ -0,0 +1,89 @@
+package go_circuit_breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"strings"
+	"testing"
+)
+
+func TestStandbyInheritsCountsOfPrimary(t *testing.T) {
+	primary := NewCircuitBreaker("test", &Strategy{Threshold: 3})
+	standby := NewCircuitBreaker("test", &Strategy{Threshold: 3})
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+
+	for i := 0; i < 3; i++ {
+		primary.Execute(errFunc)
+	}
+
+	data, err := EncodeState(primary)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, DecodeState(standby, data), nil)
+	assert.Equal(t, standby.Counts(), primary.Counts())
+
+	// the standby trips on the next failure like the primary would have
+	standby.Execute(errFunc)
+	assert.Equal(t, standby.GetState(), HalfOpen)
+}
+
+func TestStateRoundTripKeepsOpeningTime(t *testing.T) {
+	clock := newFakeClock()
+	primary := NewCircuitBreaker("test", &Strategy{Threshold: 1, Clock: clock})
+	primary.ForceOpen("test")
+
+	data, err := EncodeState(primary)
+	assert.Equal(t, err, nil)
+
+	standby := NewCircuitBreaker("test", &Strategy{})
+	assert.Equal(t, DecodeState(standby, data), nil)
+	assert.Equal(t, standby.GetState(), Open)
+	assert.Equal(t, standby.(*circuitBreaker).openedAt, clock.Now())
+}
+
+func TestUnsetTimesAreOmittedFromEncodings(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+
+	state, err := EncodeState(cb)
+	assert.Equal(t, err, nil)
+	doc, err := json.Marshal(cb)
+	assert.Equal(t, err, nil)
+
+	for _, data := range []string{string(state), string(doc)} {
+		assert.Equal(t, strings.Contains(data, "opened_at"), false)
+		assert.Equal(t, strings.Contains(data, "changed_at"), false)
+	}
+}
+
+func TestWhenStateVersionIsUnsupportedDecodingFails(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+
+	err := DecodeState(cb, []byte(`{"version":3,"state":3,"counts":{}}`))
+	assert.Equal(t, errors.Is(err, ErrStateVersion), true)
+	assert.Equal(t, cb.GetState(), Closed)
+}
+
+func TestStateOfFirstVersionIsStillDecoded(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{ManualRecoveryOnly: true})
+
+	assert.Equal(t, DecodeState(cb, []byte(`{"version":1,"state":3,"counts":{}}`)), nil)
+	assert.Equal(t, cb.GetState(), Open)
+}
+
+func TestOpenStateWithoutWayOutIsRestoredHalfOpen(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker("test", &Strategy{Clock: clock})
+
+	assert.Equal(t, DecodeState(cb, []byte(`{"version":2,"state":3,"counts":{}}`)), nil)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	res, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.GetState(), Closed)
+}
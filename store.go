@@ -0,0 +1,124 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the state of circuit breakers across restarts
+type Store interface {
+	// Load returns the state last saved for name, nil when there is none
+	Load(name string) ([]byte, error)
+	// Save is called on every state change while the circuit breaker is locked. Must not call back into it
+	Save(name string, data []byte) error
+}
+
+// NewWithStore returns new circuit breaker starting from the state last saved in store and saving every
+// state change to it. A recovery resumed from the saved state only waits for the rest of its retry interval.
+// Starts Closed when loading fails
+func NewWithStore(name string, strategy *Strategy, store Store, opts ...Option) CircuitBreaker {
+	c := NewCircuitBreaker(name, strategy, opts...).(*circuitBreaker)
+
+	data, err := store.Load(name)
+	if err == nil && data != nil {
+		err = DecodeState(c, data)
+	}
+	if err != nil {
+		c.logf("WARNING: %v circuit breaker state not loaded: %v", name, err)
+	}
+
+	c.mu.Lock()
+	c.store = store
+	c.mu.Unlock()
+	return c
+}
+
+// persist saves the state to the store if any. Must be called with the lock held
+func (c *circuitBreaker) persist() {
+	if c.store == nil {
+		return
+	}
+
+	data, err := c.encodeState()
+	if err == nil {
+		c.guard("Store", func() {
+			err = c.store.Save(c.name, data)
+		})
+	}
+	if err != nil {
+		c.logf("WARNING: %v circuit breaker state not saved: %v", c.name, err)
+	}
+}
+
+// MemoryStore keeps states in memory, surviving circuit breakers but not the process
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryStore returns new empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string][]byte)}
+}
+
+// Load implements Store
+func (s *MemoryStore) Load(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[name], nil
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// FileStore keeps the state of each circuit breaker in a file of its directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns new store keeping states in dir, which has to exist
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".json")
+}
+
+// Load implements Store
+func (s *FileStore) Load(name string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save implements Store. Replaces the file atomically so a crash never leaves a partial state behind
+func (s *FileStore) Save(name string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
@@ -0,0 +1,89 @@
+package go_circuit_breaker
+
+import (
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestBreakerFromStoreResumesRecoveryAfterRemainingCooldown(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+
+	previous := NewWithStore("test", &Strategy{Threshold: 1, Clock: clock}, store)
+	for i := 0; i < 2; i++ {
+		previous.Allow()
+		previous.Record(false)
+	}
+	assert.Equal(t, previous.GetState(), HalfOpen)
+	clock.Advance(4 * time.Second)
+
+	cb := NewWithStore("test", &Strategy{
+		RetryInterval: 10,
+		Clock:         clock,
+		ProbeFunc: func() error {
+			return nil
+		},
+	}, store)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	waitFor(t, func() bool { return clock.Pending() == 1 })
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return cb.GetState() == Closed })
+
+	// the recovery was saved as well
+	restarted := NewWithStore("test", &Strategy{Clock: clock}, store)
+	assert.Equal(t, restarted.GetState(), Closed)
+}
+
+func TestForcedStateStaysHeldAfterRestoring(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+
+	previous := NewWithStore("test", &Strategy{Clock: clock}, store)
+	previous.ForceOpen("alice")
+
+	cb := NewWithStore("test", &Strategy{
+		RetryInterval: 1,
+		Clock:         clock,
+		ProbeFunc: func() error {
+			return nil
+		},
+	}, store)
+	clock.Advance(time.Minute)
+	assert.Equal(t, cb.GetState(), Open)
+	assert.Equal(t, clock.Pending(), 0)
+
+	cb.Reset("alice")
+	restarted := NewWithStore("test", &Strategy{Clock: clock}, store)
+	assert.Equal(t, restarted.GetState(), Closed)
+}
+
+func TestFileStoreKeepsStateAcrossBreakers(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	data, err := store.Load("payments/eu")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, data == nil, true)
+
+	cb := NewWithStore("payments/eu", &Strategy{}, store)
+	assert.Equal(t, cb.GetState(), Closed)
+	cb.ForceOpen("alice")
+
+	cb = NewWithStore("payments/eu", &Strategy{}, store)
+	assert.Equal(t, cb.GetState(), Open)
+	assert.Equal(t, NewWithStore("orders", &Strategy{}, store).GetState(), Closed)
+}
+
+func TestWhenStoredStateIsCorruptBreakerStartsClosed(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save("test", []byte("not json"))
+
+	logger := &recordingLogger{}
+	cb := NewWithStore("test", &Strategy{Logger: logger}, store)
+	assert.Equal(t, cb.GetState(), Closed)
+	assert.Equal(t, len(logger.Entries()), 1)
+}
@@ -0,0 +1,52 @@
+package go_circuit_breaker
+
+import "math"
+
+const (
+	minSuggestedThreshold     = 3
+	maxSuggestedThreshold     = 20
+	minSuggestedRetryInterval = 1
+	maxSuggestedRetryInterval = 60
+)
+
+// SuggestStrategy recommends threshold and retry interval based on the accumulated stats.
+//
+// The threshold is the number of consecutive failures which is unlikely, below 0.1%, to happen
+// by chance at the observed failure rate r: ceil(ln(0.001) / ln(r)), bounded to 3..20.
+// The retry interval gives the dependency ten times the p95 latency to recover, bounded to 1..60 seconds.
+// Returns the current strategy when there were no executions yet
+func (c *circuitBreaker) SuggestStrategy() Strategy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	suggestion := *c.strategy
+	executions := c.stats.Successes + c.stats.Failures
+	if executions == 0 {
+		return suggestion
+	}
+
+	rate := float64(c.stats.Failures) / float64(executions)
+	threshold := maxSuggestedThreshold
+	if rate < 1 {
+		threshold = minSuggestedThreshold
+		if rate > 0 {
+			threshold = int(math.Ceil(math.Log(0.001) / math.Log(rate)))
+		}
+	}
+	suggestion.Threshold = clamp(threshold, minSuggestedThreshold, maxSuggestedThreshold)
+
+	p95 := c.latencies.percentile(0.95)
+	retryInterval := int(math.Ceil(p95.Seconds() * 10))
+	suggestion.RetryInterval = clamp(retryInterval, minSuggestedRetryInterval, maxSuggestedRetryInterval)
+	return suggestion
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,52 @@
+package go_circuit_breaker
+
+import (
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+// setOutcomes replaces the accumulated successes and failures
+func setOutcomes(cb CircuitBreaker, successes, failures uint64) {
+	c := cb.(*circuitBreaker)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Successes = successes
+	c.stats.Failures = failures
+}
+
+func TestSuggestStrategyFromFailureRateAndLatency(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+	setOutcomes(cb, 50, 50)
+	recordLatencies(cb, time.Millisecond*500, 100)
+
+	suggestion := cb.SuggestStrategy()
+	assert.Equal(t, suggestion.Threshold >= 9 && suggestion.Threshold <= 11, true)
+	assert.Equal(t, suggestion.RetryInterval, 5)
+}
+
+func TestSuggestStrategyStaysInBounds(t *testing.T) {
+	healthy := NewCircuitBreaker("healthy", &Strategy{})
+	setOutcomes(healthy, 100, 0)
+	recordLatencies(healthy, time.Millisecond, 100)
+
+	suggestion := healthy.SuggestStrategy()
+	assert.Equal(t, suggestion.Threshold, 3)
+	assert.Equal(t, suggestion.RetryInterval, 1)
+
+	flaky := NewCircuitBreaker("flaky", &Strategy{})
+	setOutcomes(flaky, 5, 95)
+	recordLatencies(flaky, time.Second*30, 100)
+
+	suggestion = flaky.SuggestStrategy()
+	assert.Equal(t, suggestion.Threshold, 20)
+	assert.Equal(t, suggestion.RetryInterval, 60)
+}
+
+func TestSuggestStrategyWithoutExecutionsKeepsStrategy(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 7, RetryInterval: 2})
+
+	suggestion := cb.SuggestStrategy()
+	assert.Equal(t, suggestion.Threshold, 7)
+	assert.Equal(t, suggestion.RetryInterval, 2)
+}
@@ -0,0 +1,162 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoTestMode is returned by AdvanceClock for circuit breakers not created with WithTestMode
+var ErrNoTestMode = errors.New("circuit breaker not in test mode")
+
+// ErrNotSettled is returned by AdvanceClock when background work does not settle in time
+var ErrNotSettled = errors.New("circuit breaker background work did not settle")
+
+const defaultSettleTimeout = time.Second * 5
+
+// testClock is the clock of a circuit breaker in test mode. Time only moves through AdvanceClock
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*testTimer
+	// parked counts the recoveries waiting for their next probe
+	parked int
+	// settleTimeout bounds the wait for background work blocked on anything but the clock
+	settleTimeout time.Duration
+}
+
+type testTimer struct {
+	at     time.Time
+	ch     chan time.Time
+	f      func()
+	parked bool
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(&testTimer{ch: ch}, d)
+	return ch
+}
+
+func (c *testClock) AfterFunc(d time.Duration, f func()) {
+	c.schedule(&testTimer{f: f}, d)
+}
+
+// park is After for a recovery, which counts as settled until the timer fires
+func (c *testClock) park(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(&testTimer{ch: ch, parked: true}, d)
+	return ch
+}
+
+func (c *testClock) schedule(timer *testTimer, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer.at = c.now.Add(d)
+	c.timers = append(c.timers, timer)
+	if timer.parked {
+		c.parked++
+	}
+}
+
+// next removes the earliest timer due until the target time and moves the time to it.
+// Moves the time to target and returns nil when no timer is due
+func (c *testClock) next(target time.Time) *testTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	if len(c.timers) == 0 || c.timers[0].at.After(target) {
+		c.now = target
+		return nil
+	}
+
+	timer := c.timers[0]
+	c.timers = c.timers[1:]
+	if timer.at.After(c.now) {
+		c.now = timer.at
+	}
+	if timer.parked {
+		c.parked--
+	}
+	return timer
+}
+
+func (c *testClock) parkedRecoveries() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.parked
+}
+
+// WithTestMode drives all timing of the circuit breaker by AdvanceClock instead of the system time,
+// starting at the Unix epoch. The circuit breaker uses a copy of the strategy, leaving its Clock untouched
+func WithTestMode() Option {
+	return func(c *circuitBreaker) {
+		strategy := *c.strategy
+		strategy.Clock = &testClock{now: time.Unix(0, 0).UTC(), settleTimeout: defaultSettleTimeout}
+		c.strategy = &strategy
+	}
+}
+
+// AdvanceClock moves the time of a circuit breaker in test mode forward by d. Timers due until then fire
+// in order, each only after the background work of the circuit breaker settled, so recovery probes,
+// alerts and coalesced events have run when it returns. Background work waiting on anything but the clock,
+// like a probe blocked on a channel, makes it fail with ErrNotSettled after waiting five seconds
+func AdvanceClock(cb CircuitBreaker, d time.Duration) error {
+	c, ok := cb.(*circuitBreaker)
+	if !ok {
+		return errUnknownBreaker
+	}
+	clock, ok := c.strategy.Clock.(*testClock)
+	if !ok {
+		return ErrNoTestMode
+	}
+
+	if err := c.settle(clock); err != nil {
+		return err
+	}
+	target := clock.Now().Add(d)
+	for timer := clock.next(target); timer != nil; timer = clock.next(target) {
+		if timer.f != nil {
+			timer.f()
+		} else {
+			timer.ch <- timer.at
+		}
+		if err := c.settle(clock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settle yields until every background goroutine finished or is a recovery waiting for the clock.
+// Fails with ErrNotSettled after the settle timeout of the clock
+func (c *circuitBreaker) settle(clock *testClock) error {
+	deadline := time.Now().Add(clock.settleTimeout)
+	for c.ActiveGoroutines() > clock.parkedRecoveries() {
+		if time.Now().After(deadline) {
+			return ErrNotSettled
+		}
+		runtime.Gosched()
+	}
+	return nil
+}
+
+// after returns a channel receiving the time once d passed. In test mode the waiting recovery counts as settled
+func (c *circuitBreaker) after(d time.Duration) <-chan time.Time {
+	if clock, ok := c.strategy.Clock.(*testClock); ok {
+		return clock.park(d)
+	}
+	return c.strategy.Clock.After(d)
+}
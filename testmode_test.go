@@ -0,0 +1,96 @@
+package go_circuit_breaker
+
+import (
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+	"time"
+)
+
+func TestFullLifecycleDrivenByAdvanceClock(t *testing.T) {
+	down := true
+	probes := 0
+	logger := &recordingLogger{}
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 2,
+		RetryMax:      1,
+		AlertAfter:    3 * time.Second,
+		Logger:        logger,
+		ProbeFunc: func() error {
+			probes++
+			if down {
+				return errors.New("still down")
+			}
+			return nil
+		},
+	}, WithTestMode())
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	assert.Equal(t, AdvanceClock(cb, time.Second), nil)
+	assert.Equal(t, probes, 0)
+
+	assert.Equal(t, AdvanceClock(cb, time.Second), nil)
+	assert.Equal(t, probes, 1)
+	assert.Equal(t, cb.GetState(), HalfOpen)
+
+	// the second failed probe exhausts the recovery and the alert fires on the way
+	assert.Equal(t, AdvanceClock(cb, 2*time.Second), nil)
+	assert.Equal(t, probes, 2)
+	assert.Equal(t, cb.GetState(), Open)
+	assert.Equal(t, logger.Entries(), []string{"ALERT: test circuit breaker open for 3s"})
+
+	down = false
+	assert.Equal(t, AdvanceClock(cb, 2*time.Second), nil)
+	assert.Equal(t, probes, 3)
+	assert.Equal(t, cb.GetState(), Closed)
+
+	res, err := cb.Execute(func() (interface{}, error) {
+		return "yay", nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, "yay")
+	assert.Equal(t, cb.Stats().LastRecoveryDuration, 6*time.Second)
+}
+
+func TestWhenNotInTestModeAdvanceClockFails(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{})
+	assert.Equal(t, AdvanceClock(cb, time.Second), ErrNoTestMode)
+}
+
+func TestWithTestModeLeavesStrategyClockUntouched(t *testing.T) {
+	strategy := &Strategy{}
+	cb := NewCircuitBreaker("test", strategy, WithTestMode())
+
+	assert.Equal(t, strategy.Clock, Clock(realClock{}))
+	assert.Equal(t, AdvanceClock(cb, time.Second), nil)
+	assert.Equal(t, AdvanceClock(NewCircuitBreaker("other", strategy), time.Second), ErrNoTestMode)
+}
+
+func TestWhenBackgroundWorkBlocksAdvanceClockFails(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	cb := NewCircuitBreaker("test", &Strategy{
+		Threshold:     1,
+		RetryInterval: 1,
+		ProbeFunc: func() error {
+			<-release
+			return nil
+		},
+	}, WithTestMode())
+	cb.(*circuitBreaker).strategy.Clock.(*testClock).settleTimeout = time.Millisecond * 10
+
+	errFunc := func() (interface{}, error) {
+		return nil, errors.New("i like to fail")
+	}
+	cb.Execute(errFunc)
+	cb.Execute(errFunc)
+
+	assert.Equal(t, AdvanceClock(cb, time.Second), ErrNotSettled)
+}
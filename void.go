@@ -0,0 +1,16 @@
+package go_circuit_breaker
+
+import "context"
+
+// DoVoidCtx executes f wrapped in the circuit breaker for its error only, passing ctx on to it.
+// Returns *OpenError when rejected and ctx.Err() when f failed because ctx is done, which does not count as failure
+func DoVoidCtx(ctx context.Context, cb CircuitBreaker, f func(ctx context.Context) error) error {
+	_, err := cb.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+		err := f(ctx)
+		if err != nil && ctx.Err() != nil {
+			return nil, Neutral(ctx.Err())
+		}
+		return nil, err
+	})
+	return err
+}
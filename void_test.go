@@ -0,0 +1,61 @@
+package go_circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/magiconair/properties/assert"
+	"testing"
+)
+
+func TestDoVoidCtxReturnsErrorOnly(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5})
+
+	err := DoVoidCtx(context.Background(), cb, func(ctx context.Context) error {
+		return nil
+	})
+	assert.Equal(t, err, nil)
+
+	failure := errors.New("i like to fail")
+	err = DoVoidCtx(context.Background(), cb, func(ctx context.Context) error {
+		return failure
+	})
+	assert.Equal(t, err, failure)
+	assert.Equal(t, cb.Stats().Failures, uint64(1))
+}
+
+func TestWhenOpenDoVoidCtxReturnsOpenError(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Logger: &recordingLogger{}})
+	cb.ForceOpen("test")
+
+	called := false
+	err := DoVoidCtx(context.Background(), cb, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	var openErr *OpenError
+	assert.Equal(t, errors.As(err, &openErr), true)
+	assert.Equal(t, called, false)
+}
+
+func TestWhenContextIsCancelledDoVoidCtxDoesNotCountFailure(t *testing.T) {
+	cb := NewCircuitBreaker("test", &Strategy{Threshold: 5})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := DoVoidCtx(ctx, cb, func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return errors.New("request aborted")
+	})
+
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, cb.Stats().Failures, uint64(0))
+	assert.Equal(t, cb.Counts().ConsecutiveFailures, uint64(0))
+
+	// already done contexts are not executed
+	err = DoVoidCtx(ctx, cb, func(ctx context.Context) error {
+		t.Fatal("executed with done context")
+		return nil
+	})
+	assert.Equal(t, err, context.Canceled)
+}